@@ -0,0 +1,274 @@
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy tells what AsyncWriter does with an entry, when its queue is full.
+type OverflowPolicy int
+
+const (
+	// Block makes the Write wait until there is room in the queue, so no entries are lost.
+	Block OverflowPolicy = iota
+	// DropOldest discards the oldest entry in the queue to make room for the new one.
+	DropOldest
+	// DropNewest discards the new entry.
+	DropNewest
+)
+
+// asyncReportInterval is how often AsyncWriter reports the entries it has dropped.
+var asyncReportInterval = 10 * time.Second
+
+// AsyncWriter is an io.Writer, which queues the entries written by a Logger and writes
+// them to the underlying writer from a background goroutine, so that a slow writer, such
+// as a congested pipe, does not stall the goroutines that log. The entries are encoded
+// by the logging goroutines as usual; only the final Write is deferred.
+//
+// The queue holds a fixed number of entries. When it is full, the OverflowPolicy decides
+// between waiting and dropping an entry. The dropped entries are counted, see Dropped,
+// and reported periodically by a WARNING entry written to the underlying writer, encoded
+// by the JSONEncoder, unless set otherwise by SetEncoder.
+//
+// Every Write is supposed to be a single whole entry, which holds for the Loggers.
+// The Loggers call Flush before the Fatal functions exit and before the Panic
+// functions panic, see (*Logger).Flush.
+type AsyncWriter struct {
+	w      io.Writer
+	policy OverflowPolicy
+
+	mu   sync.Mutex
+	enc  Encoder
+	cond *sync.Cond // signals any change of the fields below
+	// queue is a ring buffer of n entries starting at head.
+	queue   [][]byte
+	head, n int
+	// writing tells that the background goroutine writes an entry taken off the queue.
+	writing bool
+	// reportDue tells that the background goroutine should report the dropped entries.
+	reportDue bool
+	closed    bool
+	// dropped is the number of the dropped entries, of which reported were reported.
+	dropped, reported uint64
+
+	done chan struct{}
+	stop chan struct{}
+}
+
+// NewAsyncWriter returns an AsyncWriter writing to w, which queues up to size entries,
+// and applies the policy when the queue is full. It starts the background goroutine,
+// which runs until Close.
+func NewAsyncWriter(w io.Writer, size int, policy OverflowPolicy) *AsyncWriter {
+	if size < 1 {
+		size = 1
+	}
+
+	aw := &AsyncWriter{
+		w:      w,
+		policy: policy,
+		enc:    JSONEncoder{},
+		queue:  make([][]byte, size),
+		done:   make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	aw.cond = sync.NewCond(&aw.mu)
+
+	go aw.run()
+	go aw.tick(asyncReportInterval)
+
+	return aw
+}
+
+// Write queues a copy of p to be written to the underlying writer. It never returns an
+// error, as the errors of the underlying writer happen later. After Close, Write writes
+// to the underlying writer directly, once the queued entries are written.
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	for !aw.closed && aw.n == len(aw.queue) {
+		switch aw.policy {
+		case DropNewest:
+			aw.dropped++
+			return len(p), nil
+		case DropOldest:
+			aw.queue[aw.head] = nil
+			aw.head = (aw.head + 1) % len(aw.queue)
+			aw.n--
+			aw.dropped++
+		default:
+			aw.cond.Wait()
+		}
+	}
+
+	if aw.closed {
+		// Let the background goroutine drain the queue first, so that the entries stay
+		// in order and the writes to the underlying writer do not overlap.
+		for aw.n != 0 || aw.writing {
+			aw.cond.Wait()
+		}
+
+		return aw.w.Write(p)
+	}
+
+	aw.queue[(aw.head+aw.n)%len(aw.queue)] = append([]byte(nil), p...)
+	aw.n++
+	aw.cond.Broadcast()
+
+	return len(p), nil
+}
+
+// SetEncoder sets the Encoder of the reports of the dropped entries, which should be
+// the one of the Loggers writing to aw, see (*Logger).SetEncoder. If enc is nil, it is
+// the JSONEncoder.
+func (aw *AsyncWriter) SetEncoder(enc Encoder) {
+	if enc == nil {
+		enc = JSONEncoder{}
+	}
+
+	aw.mu.Lock()
+	aw.enc = enc
+	aw.mu.Unlock()
+}
+
+// Dropped returns the number of the entries dropped so far, because the queue was full.
+func (aw *AsyncWriter) Dropped() uint64 {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	return aw.dropped
+}
+
+// Flush waits until the queued entries are written, and then flushes the underlying
+// writer, if it has a method Flush() error, such as bufio.Writer.
+func (aw *AsyncWriter) Flush() error {
+	aw.mu.Lock()
+	for aw.n != 0 || aw.writing {
+		aw.cond.Wait()
+	}
+	aw.mu.Unlock()
+
+	return flushWriter(aw.w)
+}
+
+// Close writes the queued entries and a report of any unreported dropped entries, stops
+// the background goroutine, and flushes the underlying writer like Flush. It does not
+// close the underlying writer. It is safe to call Close more than once.
+func (aw *AsyncWriter) Close() error {
+	aw.mu.Lock()
+	if aw.closed {
+		aw.mu.Unlock()
+		return nil
+	}
+	aw.closed = true
+	aw.cond.Broadcast()
+	aw.mu.Unlock()
+
+	close(aw.stop)
+	<-aw.done
+
+	aw.mu.Lock()
+	if p := aw.reportEntry(); p != nil {
+		_, _ = aw.w.Write(p)
+	}
+	aw.mu.Unlock()
+
+	return flushWriter(aw.w)
+}
+
+// run is the background goroutine, which writes the queued entries.
+func (aw *AsyncWriter) run() {
+	defer close(aw.done)
+
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	for {
+		for aw.n == 0 && !aw.closed && !aw.reportDue {
+			aw.cond.Wait()
+		}
+
+		if aw.reportDue {
+			aw.reportDue = false
+			if p := aw.reportEntry(); p != nil {
+				aw.write(p)
+			}
+		}
+
+		if aw.n == 0 {
+			if aw.closed {
+				return
+			}
+			continue
+		}
+
+		p := aw.queue[aw.head]
+		aw.queue[aw.head] = nil
+		aw.head = (aw.head + 1) % len(aw.queue)
+		aw.n--
+		aw.write(p)
+	}
+}
+
+// write writes p to the underlying writer, with the aw.mu released meanwhile.
+// The aw.mu must be held.
+func (aw *AsyncWriter) write(p []byte) {
+	aw.writing = true
+	aw.cond.Broadcast()
+
+	aw.mu.Unlock()
+	_, _ = aw.w.Write(p)
+	aw.mu.Lock()
+
+	aw.writing = false
+	aw.cond.Broadcast()
+}
+
+// tick makes the background goroutine report the dropped entries periodically.
+func (aw *AsyncWriter) tick(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-aw.stop:
+			return
+		case <-t.C:
+			aw.mu.Lock()
+			aw.reportDue = true
+			aw.cond.Broadcast()
+			aw.mu.Unlock()
+		}
+	}
+}
+
+// reportEntry returns the encoded WARNING entry with the number of the entries dropped
+// since the last report, or nil if there are none. The aw.mu must be held.
+func (aw *AsyncWriter) reportEntry() []byte {
+	n := aw.dropped - aw.reported
+	if n == 0 {
+		return nil
+	}
+	aw.reported = aw.dropped
+
+	count := strconv.FormatUint(n, 10)
+	e := Entry{
+		Severity: SeverityWarning,
+		Message:  "log: AsyncWriter dropped " + count + " entries",
+		Fields:   json.RawMessage(`{"droppedEntries":` + count + `}`),
+	}
+
+	return encode(aw.enc, nil, &e)
+}
+
+// flushWriter flushes w, if it has a method Flush() error.
+func flushWriter(w io.Writer) error {
+	if f, ok := w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
@@ -0,0 +1,248 @@
+package log
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateWriter is a slow writer: every Write waits until the gate is closed.
+type gateWriter struct {
+	started chan struct{}
+	gate    chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	buf     bytes.Buffer
+}
+
+func newGateWriter() *gateWriter {
+	return &gateWriter{started: make(chan struct{}), gate: make(chan struct{})}
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.gate
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.Write(p)
+}
+
+func (w *gateWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.String()
+}
+
+func TestAsyncWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   OverflowPolicy
+		wantJSON string
+	}{
+		{
+			name:   "DropNewest",
+			policy: DropNewest,
+			wantJSON: `{"message":"0","severity":"INFO"}
+{"message":"1","severity":"INFO"}
+{"message":"2","severity":"INFO"}
+{"message":"log: AsyncWriter dropped 2 entries","severity":"WARNING","droppedEntries":2}
+`,
+		},
+		{
+			name:   "DropOldest",
+			policy: DropOldest,
+			wantJSON: `{"message":"0","severity":"INFO"}
+{"message":"3","severity":"INFO"}
+{"message":"4","severity":"INFO"}
+{"message":"log: AsyncWriter dropped 2 entries","severity":"WARNING","droppedEntries":2}
+`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			gw := newGateWriter()
+			aw := NewAsyncWriter(gw, 2, tt.policy)
+			l := New(aw, "", 0)
+
+			// Act
+			l.Info("0")
+			<-gw.started
+			for _, msg := range []string{"1", "2", "3", "4"} {
+				l.Info(msg)
+			}
+			dropped := aw.Dropped()
+			close(gw.gate)
+			_ = aw.Close()
+
+			// Assert
+			if dropped != 2 {
+				t.Errorf("dropped %d entries, want 2", dropped)
+			}
+			if tt.wantJSON != gw.String() {
+				t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", gw.String(), tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestAsyncWriter_Block(t *testing.T) {
+	// Arrange
+	gw := newGateWriter()
+	aw := NewAsyncWriter(gw, 1, Block)
+	l := New(aw, "", 0)
+	done := make(chan struct{})
+
+	// Act
+	go func() {
+		defer close(done)
+		for _, msg := range []string{"0", "1", "2", "3"} {
+			l.Info(msg)
+		}
+	}()
+	<-gw.started
+	select {
+	case <-done:
+		t.Errorf("logging did not block on the full queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(gw.gate)
+	<-done
+	_ = aw.Flush()
+
+	// Assert
+	wantJSON := `{"message":"0","severity":"INFO"}
+{"message":"1","severity":"INFO"}
+{"message":"2","severity":"INFO"}
+{"message":"3","severity":"INFO"}
+`
+	if wantJSON != gw.String() || aw.Dropped() != 0 {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", gw.String(), wantJSON)
+	}
+	_ = aw.Close()
+	l.Info("after close")
+	if !strings.HasSuffix(gw.String(), `{"message":"after close","severity":"INFO"}`+"\n") {
+		t.Errorf("entry logged after Close is lost:\n%s", gw.String())
+	}
+}
+
+func TestAsyncWriter_PeriodicReport(t *testing.T) {
+	// Arrange
+	defer func(d time.Duration) { asyncReportInterval = d }(asyncReportInterval)
+	asyncReportInterval = time.Millisecond
+	gw := newGateWriter()
+	aw := NewAsyncWriter(gw, 1, DropNewest)
+	defer aw.Close()
+	l := New(aw, "", 0)
+
+	// Act
+	l.Info("0")
+	<-gw.started
+	l.Info("1")
+	l.Info("2")
+	close(gw.gate)
+
+	// Assert
+	want := `{"message":"log: AsyncWriter dropped 1 entries","severity":"WARNING","droppedEntries":1}`
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(gw.String(), want); {
+		if time.Now().After(deadline) {
+			t.Fatalf("no report of the dropped entries:\n%s", gw.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAsyncWriter_Fatal(t *testing.T) {
+	// Arrange
+	gw := newGateWriter()
+	close(gw.gate)
+	aw := NewAsyncWriter(gw, 8, Block)
+	defer aw.Close()
+	l := New(aw, "", 0)
+	l.SetExitFunc(func(int) {})
+
+	// Act
+	l.Fatal("bye")
+
+	// Assert
+	wantJSON := `{"message":"bye","severity":"CRITICAL"}` + "\n"
+	if wantJSON != gw.String() {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", gw.String(), wantJSON)
+	}
+}
+
+// slowWriter is a writer, which is slow and not safe for concurrent use.
+type slowWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(10 * time.Microsecond)
+
+	return w.buf.Write(p)
+}
+
+func TestAsyncWriter_WriteDuringClose(t *testing.T) {
+	// Arrange
+	w := &slowWriter{}
+	aw := NewAsyncWriter(w, 1000, Block)
+	const n = 200
+	for i := 0; i < n/2; i++ {
+		_, _ = aw.Write([]byte(strconv.Itoa(i) + "\n"))
+	}
+	done := make(chan struct{})
+
+	// Act
+	go func() {
+		defer close(done)
+		for i := n / 2; i < n; i++ {
+			_, _ = aw.Write([]byte(strconv.Itoa(i) + "\n"))
+		}
+	}()
+	_ = aw.Close()
+	<-done
+
+	// Assert
+	lines := strings.Split(strings.TrimSuffix(w.buf.String(), "\n"), "\n")
+	if len(lines) != n {
+		t.Fatalf("%d entries, want %d", len(lines), n)
+	}
+	for i, line := range lines {
+		if line != strconv.Itoa(i) {
+			t.Fatalf("entry %d is %q, the entries are out of order", i, line)
+		}
+	}
+}
+
+func TestAsyncWriter_SetEncoder(t *testing.T) {
+	// Arrange
+	gw := newGateWriter()
+	aw := NewAsyncWriter(gw, 1, DropNewest)
+	aw.SetEncoder(LogfmtEncoder{})
+	l := New(aw, "", 0)
+	l.SetEncoder(LogfmtEncoder{})
+
+	// Act
+	l.Info("0")
+	<-gw.started
+	l.Info("1")
+	l.Info("2")
+	close(gw.gate)
+	_ = aw.Close()
+
+	// Assert
+	want := `level=info msg=0
+level=info msg=1
+level=warning msg="log: AsyncWriter dropped 1 entries" droppedEntries=1
+`
+	if want != gw.String() {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", gw.String(), want)
+	}
+}
@@ -0,0 +1,528 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The defaults of CloudLoggingConfig.
+const (
+	defaultCloudLoggingURL = "https://logging.googleapis.com"
+	defaultBatchSize       = 1000
+	defaultBatchAge        = time.Second
+	defaultMaxRetries      = 5
+	defaultRetryBackoff    = 500 * time.Millisecond
+	maxRetryBackoff        = 30 * time.Second
+	defaultRequestTimeout  = 10 * time.Second
+	defaultFlushTimeout    = 5 * time.Second
+)
+
+var (
+	// errNoProjectID is returned by NewCloudLoggingSink, when the project is unknown.
+	errNoProjectID = errors.New("log: CloudLoggingSink needs a ProjectID")
+	// errFlushTimeout is returned by Flush, when the entries are not sent in time.
+	errFlushTimeout = errors.New("log: CloudLoggingSink did not send the entries within the FlushTimeout")
+)
+
+// MonitoredResource is the resource, which produces the log entries, as described in
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/MonitoredResource, such as
+// {Type: "gce_instance", Labels: {"instance_id": "...", "zone": "..."}}.
+type MonitoredResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// CloudLoggingConfig configures a CloudLoggingSink. The zero fields select the defaults.
+type CloudLoggingConfig struct {
+	// ProjectID is the Google Cloud project of the log, by default the package var ProjectID.
+	ProjectID string
+	// LogName is the ID of the log, such as "my-app", by default ServiceName or else
+	// the name of the program.
+	LogName string
+	// Resource is the monitored resource of the entries, by default {Type: "global"}.
+	Resource *MonitoredResource
+
+	// BaseURL is the endpoint of the Cloud Logging API, by default
+	// "https://logging.googleapis.com". Tests can point it to an httptest.Server.
+	BaseURL string
+	// Client sends the requests, by default an http.Client with the timeout of 10s.
+	Client *http.Client
+	// Token returns the OAuth 2.0 access token of the requests, by default the token of
+	// the service account of the instance, obtained from the metadata server. An empty
+	// token omits the header Authorization.
+	Token func() (string, error)
+
+	// MaxBatchSize is the maximum number of entries sent by a single request, by default 1000.
+	MaxBatchSize int
+	// MaxBatchAge is the longest time an entry waits to be sent, by default 1s.
+	MaxBatchAge time.Duration
+	// BufferSize is the maximum number of entries waiting to be sent, by default ten times
+	// the MaxBatchSize. When the buffer is full, the new entries are dropped, see Dropped.
+	BufferSize int
+	// MaxRetries is the number of times a failed request is retried, by default 5. A negative
+	// value disables the retries. The 429 and 5xx status codes, and the network errors,
+	// are retried.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, by default 500ms. It doubles with
+	// every retry, up to 30s. Close cuts the waits short, giving up the retries.
+	RetryBackoff time.Duration
+	// FlushTimeout is the longest time Flush waits for the entries to be sent, by default
+	// 5s, so that a stalled endpoint does not hang the Fatal and Panic functions.
+	FlushTimeout time.Duration
+}
+
+// CloudLoggingSink is a Sink, which sends the entries directly to the Cloud Logging API
+// by the method entries.write, for the environments, in which the output of the program
+// does not reach Cloud Logging, such as plain virtual machines. The entries are sent in
+// batches from a background goroutine, when there are MaxBatchSize of them, or when
+// the oldest one has waited for MaxBatchAge. The failed requests are retried with
+// an exponential backoff.
+//
+// The Loggers flush their Sink before the Fatal functions exit and before the Panic
+// functions panic, see (*Logger).Flush. Call Close, or at least Flush, before
+// the program ends otherwise.
+type CloudLoggingSink struct {
+	cfg     CloudLoggingConfig
+	logName string
+	// resource is the encoded JSON of cfg.Resource.
+	resource []byte
+
+	mu sync.Mutex
+	// queue holds the encoded LogEntry objects waiting to be sent.
+	queue [][]byte
+	// timer sends the queue after MaxBatchAge, when armed.
+	timer  *time.Timer
+	closed bool
+	// dropped is the number of the entries lost, because the buffer was full, or because
+	// they could not be sent.
+	dropped uint64
+	// err is the first error of sending since the last Flush.
+	err error
+
+	// sendMu serializes the sending, so that Flush waits for the batches being sent.
+	sendMu sync.Mutex
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewCloudLoggingSink returns a CloudLoggingSink configured by cfg. It starts
+// the background goroutine, which runs until Close.
+func NewCloudLoggingSink(cfg CloudLoggingConfig) (*CloudLoggingSink, error) {
+	if cfg.ProjectID == "" {
+		cfg.ProjectID = ProjectID
+	}
+	if cfg.ProjectID == "" {
+		return nil, errNoProjectID
+	}
+	if cfg.LogName == "" {
+		cfg.LogName = ServiceName
+	}
+	if cfg.LogName == "" {
+		cfg.LogName = filepath.Base(os.Args[0])
+	}
+	if cfg.Resource == nil {
+		cfg.Resource = &MonitoredResource{Type: "global"}
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultCloudLoggingURL
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: defaultRequestTimeout}
+	}
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = defaultBatchSize
+	}
+	if cfg.MaxBatchAge <= 0 {
+		cfg.MaxBatchAge = defaultBatchAge
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 10 * cfg.MaxBatchSize
+	}
+	switch {
+	case cfg.MaxRetries == 0:
+		cfg.MaxRetries = defaultMaxRetries
+	case cfg.MaxRetries < 0:
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = defaultFlushTimeout
+	}
+
+	resource, err := marshalJSON(cfg.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("log: cannot marshal the Resource: %v", err)
+	}
+
+	s := &CloudLoggingSink{
+		cfg:      cfg,
+		logName:  "projects/" + cfg.ProjectID + "/logs/" + url.PathEscape(cfg.LogName),
+		resource: resource,
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if cfg.Token == nil {
+		s.cfg.Token = (&tokenCache{client: cfg.Client}).token
+	}
+	go s.run()
+
+	return s, nil
+}
+
+// WriteEntry queues the entry e to be sent. It never returns an error: the entries, which
+// cannot be queued or sent, are counted by Dropped, and the errors of sending are returned
+// by Flush. After Close, it sends the entry before returning.
+func (s *CloudLoggingSink) WriteEntry(e *Entry) error {
+	b := appendLogEntry(nil, e)
+
+	s.mu.Lock()
+	if len(s.queue) >= s.cfg.BufferSize {
+		s.dropped++
+		s.mu.Unlock()
+
+		return nil
+	}
+	s.queue = append(s.queue, b)
+	full := len(s.queue) >= s.cfg.MaxBatchSize
+	if s.timer == nil && !full && !s.closed {
+		s.timer = time.AfterFunc(s.cfg.MaxBatchAge, s.wake)
+	}
+	closed := s.closed
+	s.mu.Unlock()
+
+	switch {
+	case closed:
+		s.sendQueue()
+	case full:
+		s.wake()
+	}
+
+	return nil
+}
+
+// Dropped returns the number of the entries lost so far, because the buffer was full,
+// or because they could not be sent.
+func (s *CloudLoggingSink) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dropped
+}
+
+// Flush sends the queued entries, and waits until they and the batches being sent by
+// the background goroutine are sent, or given up. It returns the first error of sending
+// since the previous Flush, if any. If the entries are not sent within the FlushTimeout,
+// Flush returns an error, while they are still being sent in the background.
+func (s *CloudLoggingSink) Flush() error {
+	sent := make(chan struct{})
+	go func() {
+		s.sendQueue()
+		close(sent)
+	}()
+
+	t := time.NewTimer(s.cfg.FlushTimeout)
+	defer t.Stop()
+	select {
+	case <-sent:
+	case <-t.C:
+		return errFlushTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.err
+	s.err = nil
+
+	return err
+}
+
+// Close stops the background goroutine, giving up the retries of the failed requests,
+// and then flushes s, see Flush. The entries written after Close are sent immediately,
+// one by one, without retries.
+func (s *CloudLoggingSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	<-s.done
+
+	return s.Flush()
+}
+
+// wake makes the background goroutine send the queue.
+func (s *CloudLoggingSink) wake() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (s *CloudLoggingSink) run() {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.kick:
+			s.sendQueue()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// sendQueue sends all the queued entries, in batches of up to MaxBatchSize entries.
+func (s *CloudLoggingSink) sendQueue() {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	for {
+		s.mu.Lock()
+		n := len(s.queue)
+		if n > s.cfg.MaxBatchSize {
+			n = s.cfg.MaxBatchSize
+		}
+		batch := s.queue[:n:n]
+		s.queue = s.queue[n:]
+		if len(s.queue) == 0 {
+			// Let the backing array go, and the next entry arm the timer anew.
+			s.queue = nil
+			if s.timer != nil {
+				s.timer.Stop()
+				s.timer = nil
+			}
+		}
+		s.mu.Unlock()
+
+		if n == 0 {
+			return
+		}
+
+		if err := s.send(batch); err != nil {
+			s.mu.Lock()
+			s.dropped += uint64(n)
+			if s.err == nil {
+				s.err = err
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// send sends the batch of the encoded LogEntry objects, retrying as configured.
+func (s *CloudLoggingSink) send(batch [][]byte) error {
+	body := append([]byte(`{"logName":`), appendJSONString(nil, s.logName)...)
+	body = append(body, `,"resource":`...)
+	body = append(body, s.resource...)
+	body = append(body, `,"partialSuccess":true,"entries":[`...)
+	for i, b := range batch {
+		if i != 0 {
+			body = append(body, ',')
+		}
+		body = append(body, b...)
+	}
+	body = append(body, "]}"...)
+
+	backoff := s.cfg.RetryBackoff
+	for retry := 0; ; retry++ {
+		temporary, err := s.post(body)
+		if err == nil || !temporary || retry == s.cfg.MaxRetries {
+			return err
+		}
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-s.stop:
+			t.Stop()
+			return err
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// post makes a request of entries.write with the body, and tells whether its error,
+// if any, is temporary.
+func (s *CloudLoggingSink) post(body []byte) (bool, error) {
+	token, err := s.cfg.Token()
+	if err != nil {
+		return true, fmt.Errorf("log: cannot get the access token of Cloud Logging: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(s.cfg.BaseURL, "/")+"/v2/entries:write", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("log: cannot write the entries to Cloud Logging: %v", err)
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+
+	temporary := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+
+	return temporary, fmt.Errorf("log: cannot write the entries to Cloud Logging: %s: %s", resp.Status, bytes.TrimSpace(msg))
+}
+
+// appendLogEntry appends the LogEntry of the Cloud Logging API v2, as described in
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry, encoded from e.
+// The message, the prefix and the Fields of e become the jsonPayload.
+func appendLogEntry(buf []byte, e *Entry) []byte {
+	start := len(buf)
+	buf = append(buf, '{')
+
+	if name := severityName(e.Severity); name != "" {
+		buf = append(buf, `"severity":"`...)
+		buf = append(buf, name...)
+		buf = append(buf, '"')
+	}
+
+	// The entries wait in the queue, so the time of receiving them would be wrong.
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	buf = appendMemberComma(buf, start)
+	buf = append(buf, `"timestamp":"`...)
+	buf = t.UTC().AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, '"')
+
+	if e.Trace != "" {
+		buf = append(buf, `,"trace":`...)
+		buf = appendJSONString(buf, e.Trace)
+	}
+	if e.SpanID != "" {
+		buf = append(buf, `,"spanId":`...)
+		buf = appendJSONString(buf, e.SpanID)
+	}
+	if e.TraceSampled != nil && *e.TraceSampled {
+		buf = append(buf, `,"traceSampled":true`...)
+	}
+	if e.SourceLocation != nil {
+		buf = append(buf, `,"sourceLocation":`...)
+		buf = appendSourceLocation(buf, e.SourceLocation)
+	}
+	if len(e.Labels) != 0 {
+		buf = append(buf, `,"labels":`...)
+		buf = appendLabels(buf, e.Labels)
+	}
+	if e.HTTPRequest != nil {
+		buf = append(buf, `,"httpRequest":`...)
+		buf = appendJSONValue(buf, e.HTTPRequest)
+	}
+
+	buf = append(buf, `,"jsonPayload":`...)
+	payload := len(buf)
+	buf = append(buf, '{')
+	if e.Message != "" || !e.omitEmptyMessage {
+		buf = append(buf, `"message":`...)
+		buf = appendJSONString(buf, e.Message)
+	}
+	if e.Prefix != "" {
+		buf = appendMemberComma(buf, payload)
+		buf = append(buf, `"prefix":`...)
+		buf = appendJSONString(buf, e.Prefix)
+	}
+	if f := bytes.TrimSpace(e.Fields); len(f) != 0 {
+		if f[0] == '{' {
+			if f = bytes.TrimSpace(f[1 : len(f)-1]); len(f) != 0 {
+				buf = appendMemberComma(buf, payload)
+				buf = append(buf, f...)
+			}
+		} else {
+			buf = appendMemberComma(buf, payload)
+			buf = append(buf, `"value":`...)
+			buf = append(buf, f...)
+		}
+	}
+
+	return append(buf, '}', '}')
+}
+
+// metadataHostEnv is the environment variable, which overrides the host of the metadata
+// server, the same as for the Google Cloud client libraries.
+const metadataHostEnv = "GCE_METADATA_HOST"
+
+// tokenCache gets the access token of the default service account from the metadata
+// server, and keeps it until shortly before it expires.
+type tokenCache struct {
+	client *http.Client
+
+	mu      sync.Mutex
+	value   string
+	expires time.Time
+}
+
+func (c *tokenCache) token() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.value != "" && time.Now().Before(c.expires) {
+		return c.value, nil
+	}
+
+	host := os.Getenv(metadataHostEnv)
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	req, err := http.NewRequest("GET", "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server: %s", resp.Status)
+	}
+
+	var t struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", fmt.Errorf("metadata server: %v", err)
+	}
+
+	c.value = t.AccessToken
+	// Renew the token a minute early, so that it does not expire during a request.
+	c.expires = time.Now().Add(time.Duration(t.ExpiresIn)*time.Second - time.Minute)
+
+	return c.value, nil
+}
@@ -0,0 +1,401 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCloudLogging is a fake of the method entries.write of the Cloud Logging API, which
+// responds with the status codes in turn, and then with 200.
+type fakeCloudLogging struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []writeRequest
+	auth     []string
+	received chan struct{}
+}
+
+type writeRequest struct {
+	LogName        string            `json:"logName"`
+	Resource       MonitoredResource `json:"resource"`
+	PartialSuccess bool              `json:"partialSuccess"`
+	Entries        []json.RawMessage `json:"entries"`
+}
+
+func newFakeCloudLogging(statuses ...int) (*fakeCloudLogging, *httptest.Server) {
+	f := &fakeCloudLogging{statuses: statuses, received: make(chan struct{}, 100)}
+
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeCloudLogging) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req writeRequest
+	if r.Method != "POST" || r.URL.Path != "/v2/entries:write" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	status := http.StatusOK
+	if len(f.statuses) != 0 {
+		status, f.statuses = f.statuses[0], f.statuses[1:]
+	}
+	f.mu.Unlock()
+
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte("{}"))
+	f.received <- struct{}{}
+}
+
+func (f *fakeCloudLogging) wait(t *testing.T) {
+	t.Helper()
+
+	select {
+	case <-f.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request received")
+	}
+}
+
+func (f *fakeCloudLogging) writeRequests() []writeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]writeRequest(nil), f.requests...)
+}
+
+func (f *fakeCloudLogging) entries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var entries []string
+	for _, r := range f.requests {
+		for _, e := range r.Entries {
+			entries = append(entries, string(e))
+		}
+	}
+
+	return entries
+}
+
+func staticToken() (string, error) {
+	return "secret", nil
+}
+
+func TestCloudLoggingSink(t *testing.T) {
+	// Arrange
+	ProjectID = "my-project"
+	defer func() { ProjectID = "" }()
+	f, srv := newFakeCloudLogging()
+	defer srv.Close()
+	s, err := NewCloudLoggingSink(CloudLoggingConfig{
+		LogName:      "my app",
+		Resource:     &MonitoredResource{Type: "gce_instance", Labels: map[string]string{"zone": "europe-west1-b"}},
+		BaseURL:      srv.URL,
+		Token:        staticToken,
+		MaxBatchSize: 2,
+		MaxBatchAge:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	l := New(nil, "", LUTC)
+	l.SetSink(s)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Cloud-Trace-Context", "00000000000000000000000000000001/1;o=1")
+
+	// Act
+	l.ForRequest(req).WithLabels(map[string]string{"env": "test"}).Warningw("disk almost full", "free", 3)
+	l.Debugj("", []int{1})
+	f.wait(t)
+	l.Info("last")
+	err = l.Flush()
+
+	// Assert
+	if err != nil {
+		t.Errorf("Flush failed: %v", err)
+	}
+	requests := f.writeRequests()
+	if len(requests) != 2 || len(requests[0].Entries) != 2 {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+	r := requests[0]
+	if r.LogName != "projects/my-project/logs/my%20app" || r.Resource.Type != "gce_instance" || r.Resource.Labels["zone"] != "europe-west1-b" || !r.PartialSuccess {
+		t.Errorf("unexpected request %+v", r)
+	}
+	f.mu.Lock()
+	if f.auth[0] != "Bearer secret" {
+		t.Errorf("unexpected Authorization %q", f.auth[0])
+	}
+	f.mu.Unlock()
+	entries := f.entries()
+	want := []string{
+		`{"severity":"WARNING","timestamp":"TIME","trace":"projects/my-project/traces/00000000000000000000000000000001",` +
+			`"spanId":"0000000000000001","traceSampled":true,"labels":{"env":"test"},` +
+			`"jsonPayload":{"message":"disk almost full","free":3}}`,
+		`{"severity":"DEBUG","timestamp":"TIME","jsonPayload":{"value":[1]}}`,
+		`{"severity":"INFO","timestamp":"TIME","jsonPayload":{"message":"last"}}`,
+	}
+	for i, e := range entries {
+		var ts struct{ Timestamp time.Time }
+		if err := json.Unmarshal([]byte(e), &ts); err != nil || time.Since(ts.Timestamp) > time.Minute {
+			t.Errorf("unexpected timestamp in %s: %v", e, err)
+		}
+		got := strings.Replace(e, ts.Timestamp.Format(time.RFC3339Nano), "TIME", 1)
+		if got != want[i] {
+			t.Errorf("unexpected entry, got:\n%q\nexpected:\n%q\n", got, want[i])
+		}
+	}
+}
+
+func TestCloudLoggingSinkRetries(t *testing.T) {
+	// Arrange
+	f, srv := newFakeCloudLogging(http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK, http.StatusBadRequest)
+	defer srv.Close()
+	s, err := NewCloudLoggingSink(CloudLoggingConfig{
+		ProjectID:    "my-project",
+		BaseURL:      srv.URL,
+		Token:        staticToken,
+		MaxBatchAge:  time.Hour,
+		RetryBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	l := New(nil, "", 0)
+	l.SetSink(s)
+
+	// Act
+	l.Info("retried")
+	err1 := l.Flush()
+	l.Info("rejected")
+	err2 := l.Flush()
+
+	// Assert
+	if err1 != nil {
+		t.Errorf("Flush failed: %v", err1)
+	}
+	if err2 == nil || !strings.Contains(err2.Error(), "400 Bad Request") {
+		t.Errorf("unexpected error %v", err2)
+	}
+	if n := len(f.writeRequests()); n != 4 {
+		t.Errorf("%d requests, want 4", n)
+	}
+	if n := s.Dropped(); n != 1 {
+		t.Errorf("%d entries dropped, want 1", n)
+	}
+}
+
+func TestCloudLoggingSinkNoRetries(t *testing.T) {
+	// Arrange
+	f, srv := newFakeCloudLogging(http.StatusServiceUnavailable, http.StatusOK)
+	defer srv.Close()
+	s, err := NewCloudLoggingSink(CloudLoggingConfig{
+		ProjectID:    "my-project",
+		BaseURL:      srv.URL,
+		Token:        staticToken,
+		MaxBatchAge:  time.Hour,
+		MaxRetries:   -1,
+		RetryBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	l := New(nil, "", 0)
+	l.SetSink(s)
+
+	// Act
+	l.Info("not retried")
+	err = l.Flush()
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), "503 Service Unavailable") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if n := len(f.writeRequests()); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+}
+
+func TestCloudLoggingSinkBatchAge(t *testing.T) {
+	// Arrange
+	f, srv := newFakeCloudLogging()
+	defer srv.Close()
+	s, err := NewCloudLoggingSink(CloudLoggingConfig{
+		ProjectID:   "my-project",
+		BaseURL:     srv.URL,
+		Token:       staticToken,
+		MaxBatchAge: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Act
+	_ = s.WriteEntry(&Entry{Severity: SeverityInfo, Message: "aged"})
+
+	// Assert
+	f.wait(t)
+	if entries := f.entries(); len(entries) != 1 || !strings.Contains(entries[0], `"message":"aged"`) {
+		t.Errorf("unexpected entries %v", entries)
+	}
+}
+
+func TestCloudLoggingSinkBufferFull(t *testing.T) {
+	// Arrange
+	f, srv := newFakeCloudLogging()
+	defer srv.Close()
+	s, err := NewCloudLoggingSink(CloudLoggingConfig{
+		ProjectID:   "my-project",
+		BaseURL:     srv.URL,
+		Token:       staticToken,
+		MaxBatchAge: time.Hour,
+		BufferSize:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	_ = s.WriteEntry(&Entry{Message: "kept"})
+	_ = s.WriteEntry(&Entry{Message: "dropped"})
+	err = s.Close()
+	_ = s.WriteEntry(&Entry{Message: "after close"})
+
+	// Assert
+	if err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if n := s.Dropped(); n != 1 {
+		t.Errorf("%d entries dropped, want 1", n)
+	}
+	if entries := f.entries(); len(entries) != 2 || !strings.Contains(entries[0], "kept") || !strings.Contains(entries[1], "after close") {
+		t.Errorf("unexpected entries %v", entries)
+	}
+}
+
+func TestCloudLoggingSinkFlushTimeout(t *testing.T) {
+	// Arrange
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	s, err := NewCloudLoggingSink(CloudLoggingConfig{
+		ProjectID:    "my-project",
+		BaseURL:      srv.URL,
+		Token:        staticToken,
+		MaxBatchAge:  time.Hour,
+		FlushTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.WriteEntry(&Entry{Message: "stalled"})
+	start := time.Now()
+
+	// Act
+	err = s.Flush()
+
+	// Assert
+	if err != errFlushTimeout {
+		t.Errorf("unexpected error %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Flush took %v", d)
+	}
+}
+
+func TestCloudLoggingSinkCloseAbortsBackoff(t *testing.T) {
+	// Arrange
+	f, srv := newFakeCloudLogging(http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	defer srv.Close()
+	s, err := NewCloudLoggingSink(CloudLoggingConfig{
+		ProjectID:    "my-project",
+		BaseURL:      srv.URL,
+		Token:        staticToken,
+		MaxBatchSize: 1,
+		RetryBackoff: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.WriteEntry(&Entry{Message: "retried"})
+	f.wait(t)
+	start := time.Now()
+
+	// Act
+	err = s.Close()
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Close took %v", d)
+	}
+	if n := s.Dropped(); n != 1 {
+		t.Errorf("%d entries dropped, want 1", n)
+	}
+}
+
+func TestNewCloudLoggingSinkDefaultClient(t *testing.T) {
+	s, err := NewCloudLoggingSink(CloudLoggingConfig{ProjectID: "my-project", Token: staticToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if s.cfg.Client == http.DefaultClient || s.cfg.Client.Timeout == 0 {
+		t.Errorf("the default client has no timeout")
+	}
+}
+
+func TestNewCloudLoggingSinkNoProject(t *testing.T) {
+	if _, err := NewCloudLoggingSink(CloudLoggingConfig{}); err != errNoProjectID {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestTokenCache(t *testing.T) {
+	// Arrange
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"ya29.token","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	defer srv.Close()
+	defer os.Setenv(metadataHostEnv, os.Getenv(metadataHostEnv))
+	os.Setenv(metadataHostEnv, strings.TrimPrefix(srv.URL, "http://"))
+	c := &tokenCache{client: http.DefaultClient}
+
+	// Act
+	token1, err1 := c.token()
+	token2, err2 := c.token()
+	srv.Close()
+
+	// Assert
+	if err1 != nil || err2 != nil || token1 != "ya29.token" || token2 != token1 {
+		t.Errorf("unexpected tokens %q, %q, errors %v, %v", token1, token2, err1, err2)
+	}
+	if calls != 1 {
+		t.Errorf("%d calls of the metadata server, want 1", calls)
+	}
+}
@@ -0,0 +1,160 @@
+package log
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// consoleMessageWidth is the width, to which the messages are padded, so that the fields
+// following them are aligned.
+const consoleMessageWidth = 40
+
+// consoleColor tells whether FormatConsole colours the severities.
+var consoleColor = os.Getenv("NO_COLOR") == ""
+
+// consoleTags are the severity tags of FormatConsole with their ANSI colours.
+var consoleTags = map[string]struct{ tag, color string }{
+	"DEBUG":     {"DEBUG", "90"},
+	"INFO":      {"INFO", "34"},
+	"NOTICE":    {"NOTICE", "36"},
+	"WARNING":   {"WARN", "33"},
+	"ERROR":     {"ERROR", "31"},
+	"CRITICAL":  {"CRIT", "1;31"},
+	"ALERT":     {"ALERT", "1;41"},
+	"EMERGENCY": {"EMERG", "1;41"},
+}
+
+// ConsoleEncoder is the Encoder of FormatConsole. It writes human-readable lines such as:
+//
+//	15:04:05.000 WARN   main.go:12 disk almost full                 free="1.5 GB" trace=4bf92f35/00f067aa
+//
+// The severities are coloured, unless the environment variable NO_COLOR is set.
+type ConsoleEncoder struct{}
+
+// Encode appends e as a line of FormatConsole to buf.
+func (ConsoleEncoder) Encode(buf []byte, e *Entry) []byte {
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	buf = t.AppendFormat(buf, "15:04:05.000")
+	buf = append(buf, ' ')
+
+	tag, color := "-", "0"
+	if c, ok := consoleTags[severityName(e.Severity)]; ok {
+		tag, color = c.tag, c.color
+	}
+	if consoleColor {
+		buf = append(buf, "\x1b["...)
+		buf = append(buf, color...)
+		buf = append(buf, 'm')
+		buf = append(buf, tag...)
+		buf = append(buf, "\x1b[0m"...)
+	} else {
+		buf = append(buf, tag...)
+	}
+	buf = appendPadding(buf, len(tag), 7)
+
+	if e.Prefix != "" {
+		buf = appendConsoleText(buf, e.Prefix)
+		buf = append(buf, ' ')
+	}
+
+	if e.SourceLocation != nil {
+		buf = append(buf, filepath.Base(e.SourceLocation.File)...)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(e.SourceLocation.Line), 10)
+		buf = append(buf, ' ')
+	}
+
+	msg := strings.TrimRight(e.Message, "\n")
+	n := len(buf)
+	buf = appendConsoleText(buf, msg)
+
+	fields := entryFields(e)
+	if len(fields) == 0 && e.Trace == "" {
+		return append(buf, '\n')
+	}
+
+	if !strings.Contains(msg, "\n") {
+		buf = appendPadding(buf, utf8.RuneCount(buf[n:]), consoleMessageWidth)
+	}
+
+	for _, m := range fields {
+		buf = append(buf, ' ')
+		buf = appendConsoleText(buf, m.key)
+		buf = append(buf, '=')
+		buf = appendConsoleValue(buf, m.value)
+	}
+
+	if e.Trace != "" {
+		buf = append(buf, " trace="...)
+		buf = append(buf, e.Trace[strings.LastIndexByte(e.Trace, '/')+1:]...)
+		if e.SpanID != "" {
+			buf = append(buf, '/')
+			buf = append(buf, e.SpanID...)
+		}
+	}
+
+	return append(buf, '\n')
+}
+
+// appendPadding appends the spaces, which pad the text of length n to the width.
+func appendPadding(dst []byte, n, width int) []byte {
+	for ; n < width; n++ {
+		dst = append(dst, ' ')
+	}
+
+	return dst
+}
+
+// appendConsoleText appends s with the control characters, such as the ANSI escapes, and
+// the other non-printable characters escaped in the manner of Go strings, so that they
+// cannot mess with the terminal. The newlines and the tabs are kept.
+func appendConsoleText(dst []byte, s string) []byte {
+	for i, r := range s {
+		switch {
+		case r == '\n' || r == '\t':
+			dst = append(dst, byte(r))
+		case r == utf8.RuneError:
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				dst = append(dst, `\x`...)
+				dst = append(dst, hexDigits[s[i]>>4], hexDigits[s[i]&0xF])
+				continue
+			}
+			fallthrough
+		case !unicode.IsPrint(r):
+			q := strconv.QuoteRune(r)
+			dst = append(dst, q[1:len(q)-1]...)
+		default:
+			dst = append(dst, string(r)...)
+		}
+	}
+
+	return dst
+}
+
+// appendConsoleValue appends the JSON value raw, the strings unquoted unless they have
+// spaces or other troublesome characters, and the rest as they are.
+func appendConsoleValue(dst []byte, raw json.RawMessage) []byte {
+	if len(raw) == 0 || raw[0] != '"' {
+		return append(dst, raw...)
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return append(dst, raw...)
+	}
+
+	if needsQuoting(s) {
+		return strconv.AppendQuote(dst, s)
+	}
+
+	return append(dst, s...)
+}
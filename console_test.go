@@ -0,0 +1,116 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFormatConsole(t *testing.T) {
+	// Arrange
+	defer func(c bool) { consoleColor = c }(consoleColor)
+	consoleColor = false
+	ProjectID = "my-project"
+	defer func() { ProjectID = "" }()
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	l.SetFormat(FormatConsole)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Cloud-Trace-Context", "00000000000000000000000000000001/1;o=1")
+
+	// Act
+	l.Warningw("disk almost full", "free", "1.5 GB", "dev", "/dev/sda", "pct", 97.5)
+	l.Println("plain")
+	l.ForRequest(req).With("user", "alice").Error("failed")
+	l.SetSourceLocation(true)
+	l.Debugj("", map[string]interface{}{"nested": map[string]int{"a": 1}})
+
+	// Assert
+	want := []string{
+		`WARN   disk almost full                         free="1.5 GB" dev=/dev/sda pct=97.5`,
+		`INFO   plain`,
+		`ERROR  failed                                   user=alice trace=00000000000000000000000000000001/0000000000000001`,
+		`DEBUG  console_test.go:30                                          nested={"a":1}`,
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+	timestamp := regexp.MustCompile(`^\d\d:\d\d:\d\d\.\d\d\d `)
+	for i, line := range lines {
+		if !timestamp.MatchString(line) || line[13:] != want[i] {
+			t.Errorf("unexpected line, got:\n%q\nexpected:\n%q\n", line, "hh:mm:ss.000 "+want[i])
+		}
+	}
+}
+
+func TestFormatConsoleColor(t *testing.T) {
+	defer func(c bool) { consoleColor = c }(consoleColor)
+	consoleColor = true
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	l.SetFormat(FormatConsole)
+
+	l.Critical("down")
+
+	if got := buf.String()[13:]; got != "\x1b[1;31mCRIT\x1b[0m   down\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestFormatConsoleEscapes(t *testing.T) {
+	// Arrange
+	defer func(c bool) { consoleColor = c }(consoleColor)
+	consoleColor = false
+	buf := &bytes.Buffer{}
+	l := New(buf, "\x1b[2J", 0)
+	l.SetFormat(FormatConsole)
+
+	// Act
+	l.Infow("a\x1b[31mb\rc\x00\xff\u200bd\te\nf", "k\x1b", "v\x1b[0m")
+
+	// Assert
+	want := "INFO   \\x1b[2J a\\x1b[31mb\\rc\\x00\\xff\\u200bd\te\nf k\\x1b=\"v\\x1b[0m\"\n"
+	if got := buf.String()[13:]; got != want {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, want)
+	}
+}
+
+func TestLogger_outputFormat(t *testing.T) {
+	defer func(o, e bool, a, env Format) {
+		stdoutTerminal, stderrTerminal, autoFormat, envFormat = o, e, a, env
+	}(stdoutTerminal, stderrTerminal, autoFormat, envFormat)
+	stdoutTerminal, stderrTerminal, autoFormat, envFormat = true, false, FormatConsole, FormatAuto
+
+	tests := []struct {
+		name   string
+		format Format
+		env    Format
+		w      io.Writer
+		want   Format
+	}{
+		{"terminal", FormatAuto, FormatAuto, os.Stdout, FormatConsole},
+		{"not terminal", FormatAuto, FormatAuto, os.Stderr, FormatJSON},
+		{"other writer", FormatAuto, FormatAuto, &bytes.Buffer{}, FormatJSON},
+		{"env", FormatAuto, FormatJSON, os.Stdout, FormatJSON},
+		{"set", FormatJSON, FormatConsole, os.Stdout, FormatJSON},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			envFormat = tt.env
+			l := New(nil, "", 0)
+			l.SetFormat(tt.format)
+
+			got := l.outputFormat(tt.w)
+
+			if got != tt.want {
+				t.Errorf("format = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
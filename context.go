@@ -0,0 +1,216 @@
+package log
+
+import "context"
+
+// contextKey is the key of the Logger stored in a context.Context.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx which carries the Logger l. Retrieve it with FromContext,
+// or log through it with the functions like InfoCtx.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the Logger carried by ctx, as stored by NewContext. If there is none,
+// it returns the package-level logger, the one used by functions like Info.
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*Logger); ok && l != nil {
+			return l
+		}
+	}
+
+	return &std
+}
+
+// DebugCtx logs detailed information that could mainly be used to catch unforeseen problems, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Print.
+func DebugCtx(ctx context.Context, v ...interface{}) {
+	log(SeverityDebug, FromContext(ctx), 2, v...)
+}
+
+// DebuglnCtx logs detailed information that could mainly be used to catch unforeseen problems, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Println.
+func DebuglnCtx(ctx context.Context, v ...interface{}) {
+	logln(SeverityDebug, FromContext(ctx), 2, v...)
+}
+
+// DebugfCtx logs detailed information that could mainly be used to catch unforeseen problems, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Printf.
+func DebugfCtx(ctx context.Context, format string, v ...interface{}) {
+	logf(SeverityDebug, FromContext(ctx), 2, format, v...)
+}
+
+// DebugjCtx logs detailed information that could mainly be used to catch unforeseen problems, through the Logger carried by ctx.
+// Argument v becomes the jsonPayload field of the log entry.
+func DebugjCtx(ctx context.Context, msg string, v interface{}) {
+	logj(SeverityDebug, FromContext(ctx), 2, msg, v)
+}
+
+// InfoCtx logs routine information, such as ongoing status or performance, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Print.
+func InfoCtx(ctx context.Context, v ...interface{}) {
+	log(SeverityInfo, FromContext(ctx), 2, v...)
+}
+
+// InfolnCtx logs routine information, such as ongoing status or performance, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Println.
+func InfolnCtx(ctx context.Context, v ...interface{}) {
+	logln(SeverityInfo, FromContext(ctx), 2, v...)
+}
+
+// InfofCtx logs routine information, such as ongoing status or performance, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Printf.
+func InfofCtx(ctx context.Context, format string, v ...interface{}) {
+	logf(SeverityInfo, FromContext(ctx), 2, format, v...)
+}
+
+// InfojCtx logs routine information, such as ongoing status or performance, through the Logger carried by ctx.
+// Argument v becomes the jsonPayload field of the log entry.
+func InfojCtx(ctx context.Context, msg string, v interface{}) {
+	logj(SeverityInfo, FromContext(ctx), 2, msg, v)
+}
+
+// NoticeCtx logs normal but significant events, such as start up, shut down, or configuration, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Print.
+func NoticeCtx(ctx context.Context, v ...interface{}) {
+	log(SeverityNotice, FromContext(ctx), 2, v...)
+}
+
+// NoticelnCtx logs normal but significant events, such as start up, shut down, or configuration, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Println.
+func NoticelnCtx(ctx context.Context, v ...interface{}) {
+	logln(SeverityNotice, FromContext(ctx), 2, v...)
+}
+
+// NoticefCtx logs normal but significant events, such as start up, shut down, or configuration, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Printf.
+func NoticefCtx(ctx context.Context, format string, v ...interface{}) {
+	logf(SeverityNotice, FromContext(ctx), 2, format, v...)
+}
+
+// NoticejCtx logs normal but significant events, such as start up, shut down, or configuration, through the Logger carried by ctx.
+// Argument v becomes the jsonPayload field of the log entry.
+func NoticejCtx(ctx context.Context, msg string, v interface{}) {
+	logj(SeverityNotice, FromContext(ctx), 2, msg, v)
+}
+
+// WarningCtx logs events that might cause problems, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Print.
+func WarningCtx(ctx context.Context, v ...interface{}) {
+	log(SeverityWarning, FromContext(ctx), 2, v...)
+}
+
+// WarninglnCtx logs events that might cause problems, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Println.
+func WarninglnCtx(ctx context.Context, v ...interface{}) {
+	logln(SeverityWarning, FromContext(ctx), 2, v...)
+}
+
+// WarningfCtx logs events that might cause problems, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Printf.
+func WarningfCtx(ctx context.Context, format string, v ...interface{}) {
+	logf(SeverityWarning, FromContext(ctx), 2, format, v...)
+}
+
+// WarningjCtx logs events that might cause problems, through the Logger carried by ctx.
+// Argument v becomes the jsonPayload field of the log entry.
+func WarningjCtx(ctx context.Context, msg string, v interface{}) {
+	logj(SeverityWarning, FromContext(ctx), 2, msg, v)
+}
+
+// ErrorCtx logs events likely to cause problems, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Print.
+func ErrorCtx(ctx context.Context, v ...interface{}) {
+	log(SeverityError, FromContext(ctx), 2, v...)
+}
+
+// ErrorlnCtx logs events likely to cause problems, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Println.
+func ErrorlnCtx(ctx context.Context, v ...interface{}) {
+	logln(SeverityError, FromContext(ctx), 2, v...)
+}
+
+// ErrorfCtx logs events likely to cause problems, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Printf.
+func ErrorfCtx(ctx context.Context, format string, v ...interface{}) {
+	logf(SeverityError, FromContext(ctx), 2, format, v...)
+}
+
+// ErrorjCtx logs events likely to cause problems, through the Logger carried by ctx.
+// Argument v becomes the jsonPayload field of the log entry.
+func ErrorjCtx(ctx context.Context, msg string, v interface{}) {
+	logj(SeverityError, FromContext(ctx), 2, msg, v)
+}
+
+// CriticalCtx logs events that cause more severe problems or outages, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Print.
+func CriticalCtx(ctx context.Context, v ...interface{}) {
+	log(SeverityCritical, FromContext(ctx), 2, v...)
+}
+
+// CriticallnCtx logs events that cause more severe problems or outages, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Println.
+func CriticallnCtx(ctx context.Context, v ...interface{}) {
+	logln(SeverityCritical, FromContext(ctx), 2, v...)
+}
+
+// CriticalfCtx logs events that cause more severe problems or outages, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Printf.
+func CriticalfCtx(ctx context.Context, format string, v ...interface{}) {
+	logf(SeverityCritical, FromContext(ctx), 2, format, v...)
+}
+
+// CriticaljCtx logs events that cause more severe problems or outages, through the Logger carried by ctx.
+// Argument v becomes the jsonPayload field of the log entry.
+func CriticaljCtx(ctx context.Context, msg string, v interface{}) {
+	logj(SeverityCritical, FromContext(ctx), 2, msg, v)
+}
+
+// AlertCtx logs when a person must take an action immediately, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Print.
+func AlertCtx(ctx context.Context, v ...interface{}) {
+	log(SeverityAlert, FromContext(ctx), 2, v...)
+}
+
+// AlertlnCtx logs when a person must take an action immediately, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Println.
+func AlertlnCtx(ctx context.Context, v ...interface{}) {
+	logln(SeverityAlert, FromContext(ctx), 2, v...)
+}
+
+// AlertfCtx logs when a person must take an action immediately, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Printf.
+func AlertfCtx(ctx context.Context, format string, v ...interface{}) {
+	logf(SeverityAlert, FromContext(ctx), 2, format, v...)
+}
+
+// AlertjCtx logs when a person must take an action immediately, through the Logger carried by ctx.
+// Argument v becomes the jsonPayload field of the log entry.
+func AlertjCtx(ctx context.Context, msg string, v interface{}) {
+	logj(SeverityAlert, FromContext(ctx), 2, msg, v)
+}
+
+// EmergencyCtx logs when one or more systems are unusable, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Print.
+func EmergencyCtx(ctx context.Context, v ...interface{}) {
+	log(SeverityEmergency, FromContext(ctx), 2, v...)
+}
+
+// EmergencylnCtx logs when one or more systems are unusable, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Println.
+func EmergencylnCtx(ctx context.Context, v ...interface{}) {
+	logln(SeverityEmergency, FromContext(ctx), 2, v...)
+}
+
+// EmergencyfCtx logs when one or more systems are unusable, through the Logger carried by ctx.
+// Arguments are handled in the manner of fmt.Printf.
+func EmergencyfCtx(ctx context.Context, format string, v ...interface{}) {
+	logf(SeverityEmergency, FromContext(ctx), 2, format, v...)
+}
+
+// EmergencyjCtx logs when one or more systems are unusable, through the Logger carried by ctx.
+// Argument v becomes the jsonPayload field of the log entry.
+func EmergencyjCtx(ctx context.Context, msg string, v interface{}) {
+	logj(SeverityEmergency, FromContext(ctx), 2, msg, v)
+}
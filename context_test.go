@@ -0,0 +1,42 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	l := New(&bytes.Buffer{}, "", 0)
+
+	if got := FromContext(context.Background()); got != &std {
+		t.Errorf("FromContext() of an empty context = %p, want the std logger %p", got, &std)
+	}
+	if got := FromContext(NewContext(context.Background(), l)); got != l {
+		t.Errorf("FromContext() = %p, want %p", got, l)
+	}
+	if got := FromContext(NewContext(context.Background(), nil)); got != &std {
+		t.Errorf("FromContext() of a nil Logger = %p, want the std logger %p", got, &std)
+	}
+}
+
+func TestInfofCtx(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"a 1","severity":"INFO","logging.googleapis.com/trace":"123"}
+{"message":"b","severity":"WARNING","logging.googleapis.com/trace":"123","K":"v"}
+`
+	buf := &bytes.Buffer{}
+	l := &Logger{out: buf, trace: []byte(`"123"`)}
+	l.SetLevel(SeverityInfo)
+	ctx := NewContext(context.Background(), l)
+
+	// Act
+	InfofCtx(ctx, "a %d", 1)
+	WarningjCtx(ctx, "b", struct{ K string }{"v"})
+	DebugCtx(ctx, "c")
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), wantJSON)
+	}
+}
@@ -0,0 +1,544 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxPooledBuffer is the capacity above which the buffers are not returned to the pool,
+// so that a single huge entry does not pin its memory forever.
+const maxPooledBuffer = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(b *[]byte) {
+	if cap(*b) > maxPooledBuffer {
+		return
+	}
+	*b = (*b)[:0]
+	bufferPool.Put(b)
+}
+
+// jsonEncoder is a json.Encoder together with its buffer, which are pooled by marshalJSON.
+type jsonEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var jsonEncoderPool = sync.Pool{
+	New: func() interface{} {
+		e := &jsonEncoder{}
+		e.enc = json.NewEncoder(&e.buf)
+		e.enc.SetEscapeHTML(false)
+		return e
+	},
+}
+
+// Entry is a log entry, as passed to an Encoder. The logging functions build it from their
+// arguments and from the Logger, which logs it.
+type Entry struct {
+	Severity Severity
+	Message  string
+	// Time is the time of the entry, or the zero time, if the flags of the Logger omit
+	// the timestamp, see SetFlags.
+	Time time.Time
+	// Trace is the resource name of the trace, such as
+	// "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736", see ForRequest.
+	// The SpanID and the TraceSampled are set only together with the Trace, if known.
+	Trace        string
+	SpanID       string
+	TraceSampled *bool
+	// Labels are set by WithLabels, and HTTPRequest by WithHTTPRequest or Middleware.
+	Labels      map[string]string
+	HTTPRequest *HTTPRequest
+	// SourceLocation is the caller, if enabled by SetSourceLocation.
+	SourceLocation *SourceLocation
+	// Prefix is the prefix of the Logger, unless the flag Lmsgprefix prepends it
+	// to the Message.
+	Prefix string
+	// Fields are the rest of the jsonPayload as an encoded JSON object, such as
+	// {"user":"alice"}, or nil. They are the error report, see SetErrorReporting, followed
+	// by the fields of the Logger, see With, and by the fields of the logging call.
+	// A value, which is not an object, such as the argument of Printj(2), is under
+	// the key "value".
+	Fields json.RawMessage
+
+	// omitEmptyMessage tells to omit the field "message", if the message is empty.
+	omitEmptyMessage bool
+}
+
+// Encoder encodes the entries written by a Logger, see SetEncoder.
+type Encoder interface {
+	// Encode appends the encoded entry e, terminated by a new line, to buf and returns
+	// the extended buffer. Neither e nor its Fields may be retained after Encode returns.
+	Encode(buf []byte, e *Entry) []byte
+}
+
+// JSONEncoder is the Encoder of FormatJSON, the default one. It writes the one-line JSON
+// entries understood by Cloud Logging, with the fields in the order: message, severity,
+// timestamp, trace, span ID, sampling decision, source location, prefix, labels, HTTP
+// request, and finally the Fields.
+type JSONEncoder struct{}
+
+// Encode appends the JSON encoding of e to buf.
+func (JSONEncoder) Encode(buf []byte, e *Entry) []byte {
+	start := len(buf)
+	buf = append(buf, '{')
+
+	if e.Message != "" || !e.omitEmptyMessage {
+		buf = append(buf, `"message":`...)
+		buf = appendJSONString(buf, e.Message)
+	}
+
+	if name := severityName(e.Severity); name != "" {
+		buf = appendMemberComma(buf, start)
+		buf = append(buf, `"severity":"`...)
+		buf = append(buf, name...)
+		buf = append(buf, '"')
+	}
+
+	if !e.Time.IsZero() {
+		buf = appendMemberComma(buf, start)
+		buf = append(buf, `"timestamp":"`...)
+		buf = appendTimestamp(buf, e.Time)
+		buf = append(buf, '"')
+	}
+
+	if e.Trace != "" {
+		buf = appendMemberComma(buf, start)
+		buf = append(buf, `"logging.googleapis.com/trace":`...)
+		buf = appendJSONString(buf, e.Trace)
+	}
+
+	if e.SpanID != "" {
+		buf = appendMemberComma(buf, start)
+		buf = append(buf, `"logging.googleapis.com/spanId":`...)
+		buf = appendJSONString(buf, e.SpanID)
+	}
+
+	if e.TraceSampled != nil {
+		buf = appendMemberComma(buf, start)
+		buf = append(buf, `"logging.googleapis.com/trace_sampled":`...)
+		buf = strconv.AppendBool(buf, *e.TraceSampled)
+	}
+
+	if e.SourceLocation != nil {
+		buf = appendMemberComma(buf, start)
+		buf = append(buf, `"logging.googleapis.com/sourceLocation":`...)
+		buf = appendSourceLocation(buf, e.SourceLocation)
+	}
+
+	if e.Prefix != "" {
+		buf = appendMemberComma(buf, start)
+		buf = append(buf, `"prefix":`...)
+		buf = appendJSONString(buf, e.Prefix)
+	}
+
+	if len(e.Labels) != 0 {
+		buf = appendMemberComma(buf, start)
+		buf = append(buf, `"logging.googleapis.com/labels":`...)
+		buf = appendLabels(buf, e.Labels)
+	}
+
+	if e.HTTPRequest != nil {
+		buf = appendMemberComma(buf, start)
+		buf = append(buf, `"httpRequest":`...)
+		buf = appendJSONValue(buf, e.HTTPRequest)
+	}
+
+	if f := bytes.TrimSpace(e.Fields); len(f) != 0 {
+		if f[0] == '{' {
+			// Merge the members, if any, into the entry.
+			if f = bytes.TrimSpace(f[1 : len(f)-1]); len(f) != 0 {
+				buf = appendMemberComma(buf, start)
+				buf = append(buf, f...)
+			}
+		} else {
+			buf = appendMemberComma(buf, start)
+			buf = append(buf, `"value":`...)
+			buf = append(buf, f...)
+		}
+	}
+
+	return append(buf, '}', '\n')
+}
+
+// appendMemberComma appends a comma, unless buf[start:] is just the opening brace.
+func appendMemberComma(buf []byte, start int) []byte {
+	if len(buf) > start+1 {
+		buf = append(buf, ',')
+	}
+
+	return buf
+}
+
+// appendComma appends a comma to the JSON object members in buf, unless there are none.
+func appendComma(buf []byte) []byte {
+	if len(buf) != 0 && buf[len(buf)-1] != '{' {
+		buf = append(buf, ',')
+	}
+
+	return buf
+}
+
+// write completes the entry e with the trace, the labels, the HTTP request and the fields
+// of l, preceded in the Fields by the error report and followed by the payload, which are
+// encoded JSON object members and an encoded JSON value respectively. Then it passes e to
+// the Sink of l, if any, or it encodes e and writes it through l by a single Write.
+func (l *Logger) write(e *Entry, report, payload []byte) error {
+	if len(l.trace) != 0 {
+		e.Trace = unquoteJSON(l.trace)
+		e.SpanID = unquoteJSON(l.spanID)
+		if len(l.sampled) != 0 {
+			sampled := string(l.sampled) == "true"
+			e.TraceSampled = &sampled
+		}
+	}
+	e.Labels = l.labels
+	e.HTTPRequest = l.httpRequest
+
+	fb := getBuffer()
+	*fb = appendFields(*fb, report, l.fields, payload)
+	if len(*fb) != 0 {
+		e.Fields = *fb
+	}
+
+	if s := l.Sink(); s != nil {
+		err := writeSink(s, e)
+		putBuffer(fb)
+
+		return err
+	}
+
+	b := getBuffer()
+	// Most of the time the Encoder does not depend on the writer, and the entry is
+	// encoded before taking the lock.
+	enc := l.encoderFor(nil)
+	if enc != nil {
+		*b = encode(enc, *b, e)
+	}
+
+	r := l.root()
+	r.mu.Lock()
+	w := r.writer(e.Severity)
+	if enc == nil {
+		*b = encode(l.encoderFor(w), *b, e)
+	}
+	_, err := w.Write(*b)
+	r.mu.Unlock()
+
+	putBuffer(b)
+	putBuffer(fb)
+
+	return err
+}
+
+// encode is enc.Encode(buf, e), except the built-in Encoders are called directly, so that
+// e does not escape to the heap, unless enc is a custom one.
+func encode(enc Encoder, buf []byte, e *Entry) []byte {
+	switch enc := enc.(type) {
+	case JSONEncoder:
+		return enc.Encode(buf, e)
+	case ConsoleEncoder:
+		return enc.Encode(buf, e)
+	case LogfmtEncoder:
+		return enc.Encode(buf, e)
+	}
+
+	c := *e
+
+	return enc.Encode(buf, &c)
+}
+
+// appendFields appends the Fields of an Entry: a JSON object of the members of report and
+// fields, and of the value payload, which is merged into the object, if it is an object
+// itself, or becomes the member "value". If there are no members, nothing is appended.
+func appendFields(buf, report, fields, payload []byte) []byte {
+	start := len(buf)
+	buf = append(buf, '{')
+
+	if len(report) != 0 {
+		buf = append(buf, report...)
+	}
+
+	if len(fields) != 0 {
+		buf = appendMemberComma(buf, start)
+		buf = append(buf, fields...)
+	}
+
+	switch p := bytes.TrimSpace(payload); {
+	case len(p) == 0:
+	case p[0] != '{':
+		buf = appendMemberComma(buf, start)
+		buf = append(buf, `"value":`...)
+		buf = append(buf, p...)
+	default:
+		if p = bytes.TrimSpace(p[1 : len(p)-1]); len(p) != 0 {
+			buf = appendMemberComma(buf, start)
+			buf = append(buf, p...)
+		}
+	}
+
+	if len(buf) == start+1 {
+		return buf[:start]
+	}
+
+	return append(buf, '}')
+}
+
+// unquoteJSON returns the value of the encoded JSON string b, or the empty string if b is
+// not a string.
+func unquoteJSON(b []byte) string {
+	if len(b) < 2 || b[0] != '"' {
+		return ""
+	}
+	if bytes.IndexByte(b, '\\') < 0 {
+		return string(b[1 : len(b)-1])
+	}
+
+	var s string
+	_ = json.Unmarshal(b, &s)
+
+	return s
+}
+
+// appendTimestamp appends t in the format of RFC 3339, with the fraction of a second only
+// as precise as t is: none, microseconds, or nanoseconds.
+func appendTimestamp(buf []byte, t time.Time) []byte {
+	layout := time.RFC3339Nano
+	switch ns := t.Nanosecond(); {
+	case ns == 0:
+		layout = time.RFC3339
+	case ns%1000 == 0:
+		layout = "2006-01-02T15:04:05.000000Z07:00"
+	}
+
+	return t.AppendFormat(buf, layout)
+}
+
+// appendLabels appends the JSON object of the labels, sorted by the keys.
+func appendLabels(buf []byte, labels map[string]string) []byte {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf = append(buf, '{')
+	for i, k := range keys {
+		if i != 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, k)
+		buf = append(buf, ':')
+		buf = appendJSONString(buf, labels[k])
+	}
+
+	return append(buf, '}')
+}
+
+// severityName returns the name of the severity s, such as "WARNING", or the empty
+// string for DEFAULT and for the unknown severities, which are omitted from the entries.
+func severityName(s Severity) string {
+	switch s {
+	case SeverityDebug:
+		return "DEBUG"
+	case SeverityInfo:
+		return "INFO"
+	case SeverityNotice:
+		return "NOTICE"
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityError:
+		return "ERROR"
+	case SeverityCritical:
+		return "CRITICAL"
+	case SeverityAlert:
+		return "ALERT"
+	case SeverityEmergency:
+		return "EMERGENCY"
+	default:
+		return ""
+	}
+}
+
+// appendSourceLocation appends the JSON encoding of loc, the same as marshalJSON would.
+func appendSourceLocation(buf []byte, loc *SourceLocation) []byte {
+	buf = append(buf, `{"file":`...)
+	buf = appendJSONString(buf, loc.File)
+	buf = append(buf, `,"line":"`...)
+	buf = strconv.AppendInt(buf, int64(loc.Line), 10)
+	buf = append(buf, '"')
+	if loc.Function != "" {
+		buf = append(buf, `,"function":`...)
+		buf = appendJSONString(buf, loc.Function)
+	}
+
+	return append(buf, '}')
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string, escaped the same as by marshalJSON: the HTML
+// characters are left alone, the invalid UTF-8 becomes U+FFFD, and the U+2028 and U+2029
+// are escaped for the sake of JavaScript.
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' {
+				i++
+				continue
+			}
+
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				buf = append(buf, '\\', b)
+			case '\b':
+				buf = append(buf, '\\', 'b')
+			case '\f':
+				buf = append(buf, '\\', 'f')
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+
+			continue
+		}
+
+		c, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case c == utf8.RuneError && size == 1:
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, "\ufffd"...)
+		case c == '\u2028' || c == '\u2029':
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hexDigits[c&0xF])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+
+	buf = append(buf, s[start:]...)
+
+	return append(buf, '"')
+}
+
+// appendJSONValue appends the JSON encoding of v. The common types are encoded directly,
+// as described for Logw, and the rest by marshalJSON.
+func appendJSONValue(buf []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, "null"...)
+	case string:
+		return appendJSONString(buf, v)
+	case bool:
+		return strconv.AppendBool(buf, v)
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(buf, v, 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(buf, v, 10)
+	case float32:
+		return appendJSONFloat(buf, float64(v), 32)
+	case float64:
+		return appendJSONFloat(buf, v, 64)
+	case time.Time:
+		buf = append(buf, '"')
+		buf = v.AppendFormat(buf, time.RFC3339Nano)
+		return append(buf, '"')
+	case time.Duration:
+		return appendJSONString(buf, formatDuration(v))
+	case error:
+		if isNilPointer(v) {
+			return append(buf, "null"...)
+		}
+		return appendJSONString(buf, v.Error())
+	}
+
+	// Do not include the err, for the same reasons as in logj.
+	b, err := marshalJSON(v)
+	if err != nil {
+		b = []byte(`{"logLibMsg":"cannot marshal the value"}`)
+	}
+
+	return append(buf, b...)
+}
+
+// isNilPointer reports whether v holds a nil pointer, such as an error of the value
+// (*MyError)(nil), whose methods are likely to panic.
+func isNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// appendJSONFloat appends f the same as marshalJSON does, except the NaN and the infinities,
+// which marshalJSON rejects, become strings. The bits are 32 for a float32, or 64.
+func appendJSONFloat(buf []byte, f float64, bits int) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.AppendQuote(buf, strconv.FormatFloat(f, 'g', -1, bits))
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+
+	buf = strconv.AppendFloat(buf, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9, as does encoding/json.
+		if n := len(buf); n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+
+	return buf
+}
@@ -0,0 +1,244 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendJSONString(t *testing.T) {
+	tests := []string{
+		"",
+		"plain",
+		`quote " and backslash \`,
+		"<html> & 'apostrophe'",
+		"new\nline\r\ttab",
+		"\x00\x01\x1f\x7f",
+		"żółć 日本語 🙂",
+		"invalid \xff\xfe utf-8 \xe2\x82",
+		"line separators \u2028 \u2029",
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt, func(t *testing.T) {
+			want, err := marshalJSON(tt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := appendJSONString(nil, tt)
+
+			if !bytes.Equal(want, got) {
+				t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, want)
+			}
+		})
+	}
+}
+
+func TestAppendJSONStringShortEscapes(t *testing.T) {
+	got := string(appendJSONString(nil, "\b\f"))
+
+	var s string
+	if err := json.Unmarshal([]byte(got), &s); err != nil || s != "\b\f" || got != `"\b\f"` {
+		t.Errorf("unexpected output %q: %v", got, err)
+	}
+}
+
+func TestAppendSourceLocation(t *testing.T) {
+	for _, loc := range []*SourceLocation{
+		{File: "a/b.go", Line: 12, Function: "main.f"},
+		{File: `c:\d.go`, Line: 1},
+	} {
+		want, _ := marshalJSON(loc)
+
+		got := appendSourceLocation(nil, loc)
+
+		if !bytes.Equal(want, got) {
+			t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, want)
+		}
+	}
+}
+
+// recordingEncoder is an Encoder, which records the entries and writes their messages.
+type recordingEncoder struct {
+	entries []Entry
+}
+
+func (r *recordingEncoder) Encode(buf []byte, e *Entry) []byte {
+	c := *e
+	c.Fields = append(json.RawMessage(nil), e.Fields...)
+	r.entries = append(r.entries, c)
+
+	return append(append(buf, e.Message...), '\n')
+}
+
+func TestLogger_SetEncoder(t *testing.T) {
+	// Arrange
+	ProjectID = "my-project"
+	defer func() { ProjectID = "" }()
+	buf := &bytes.Buffer{}
+	l := New(buf, "app: ", LUTC|Lmicroseconds|Lshortfile)
+	enc := &recordingEncoder{}
+	l.SetEncoder(enc)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Cloud-Trace-Context", "00000000000000000000000000000001/1;o=1")
+	hr := &HTTPRequest{RequestMethod: "GET", Status: 200}
+	c := l.ForRequest(req).WithLabels(map[string]string{"env": "test"}).WithHTTPRequest(hr).With("user", "alice")
+
+	// Act
+	c.Warningw("low disk", "free", 3)
+	l.SetEncoder(nil)
+	l.Info("back to JSON")
+
+	// Assert
+	if len(enc.entries) != 1 {
+		t.Fatalf("%d entries, want 1", len(enc.entries))
+	}
+	e := enc.entries[0]
+	if e.Severity != SeverityWarning || e.Message != "low disk" || e.Prefix != "app: " {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e.Time.IsZero() || e.Time.Location() != time.UTC || e.Time.Nanosecond()%1000 != 0 {
+		t.Errorf("unexpected time %v", e.Time)
+	}
+	if e.Trace != "projects/my-project/traces/00000000000000000000000000000001" || e.SpanID != "0000000000000001" || e.TraceSampled == nil || !*e.TraceSampled {
+		t.Errorf("unexpected trace %q, span %q, sampled %v", e.Trace, e.SpanID, e.TraceSampled)
+	}
+	if e.Labels["env"] != "test" || e.HTTPRequest != hr {
+		t.Errorf("unexpected labels %v, HTTP request %v", e.Labels, e.HTTPRequest)
+	}
+	if e.SourceLocation == nil || e.SourceLocation.File != "encoder_test.go" {
+		t.Errorf("unexpected source location %+v", e.SourceLocation)
+	}
+	if got, want := string(e.Fields), `{"user":"alice","free":3}`; got != want {
+		t.Errorf("unexpected fields, got:\n%q\nexpected:\n%q\n", got, want)
+	}
+	if lines := strings.Split(buf.String(), "\n"); len(lines) != 3 || lines[0] != "low disk" || !strings.HasPrefix(lines[1], `{"message":"back to JSON"`) {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	sampled := false
+	e := Entry{
+		Severity:       SeverityError,
+		Message:        "failed",
+		Time:           time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Trace:          "projects/p/traces/1",
+		SpanID:         "2",
+		TraceSampled:   &sampled,
+		Labels:         map[string]string{"b": "2", "a": "1"},
+		HTTPRequest:    &HTTPRequest{Status: 500},
+		SourceLocation: &SourceLocation{File: "main.go", Line: 3},
+		Prefix:         "app",
+		Fields:         json.RawMessage(`{"user":"alice"}`),
+	}
+	want := `{"message":"failed","severity":"ERROR","timestamp":"2024-05-06T07:08:09Z",` +
+		`"logging.googleapis.com/trace":"projects/p/traces/1","logging.googleapis.com/spanId":"2",` +
+		`"logging.googleapis.com/trace_sampled":false,"logging.googleapis.com/sourceLocation":{"file":"main.go","line":"3"},` +
+		`"prefix":"app","logging.googleapis.com/labels":{"a":"1","b":"2"},"httpRequest":{"status":500},"user":"alice"}` + "\n"
+
+	got := string(JSONEncoder{}.Encode(nil, &e))
+
+	if got != want {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, want)
+	}
+}
+
+func TestAppendTimestamp(t *testing.T) {
+	tests := []struct {
+		ns   int
+		want string
+	}{
+		{0, "2024-05-06T07:08:09Z"},
+		{120000000, "2024-05-06T07:08:09.120000Z"},
+		{123456789, "2024-05-06T07:08:09.123456789Z"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.want, func(t *testing.T) {
+			got := string(appendTimestamp(nil, time.Date(2024, 5, 6, 7, 8, 9, tt.ns, time.UTC)))
+
+			if got != tt.want {
+				t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items at random under the race detector")
+	}
+
+	l := New(ioutil.Discard, "", 0)
+	c := l.With("a", 1)
+	msg := "hello"
+
+	tests := []struct {
+		name   string
+		log    func()
+		format func()
+	}{
+		{"Print", func() { l.Print(msg) }, func() { _ = fmt.Sprint(msg) }},
+		{"Printf", func() { l.Printf("%q", msg) }, func() { _ = fmt.Sprintf("%q", msg) }},
+		{"Println", func() { l.Println(msg) }, func() { _ = fmt.Sprintln(msg) }},
+		{"With", func() { c.Print(msg) }, func() { _ = fmt.Sprint(msg) }},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := testing.AllocsPerRun(100, tt.log)
+			want := testing.AllocsPerRun(100, tt.format)
+
+			if got > want {
+				t.Errorf("%v allocations per entry, want %v of the formatting alone", got, want)
+			}
+		})
+	}
+}
+
+func BenchmarkPrint(b *testing.B) {
+	l := New(ioutil.Discard, "", 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Print("test")
+	}
+}
+
+func BenchmarkPrintf(b *testing.B) {
+	l := New(ioutil.Discard, "", 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Printf("%q", "test")
+	}
+}
+
+func BenchmarkPrintln(b *testing.B) {
+	l := New(ioutil.Discard, "", 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Println("test")
+	}
+}
+
+func BenchmarkPrintWithFields(b *testing.B) {
+	l := New(ioutil.Discard, "", 0).With("a", 1, "b", "two")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Print("test")
+	}
+}
+
+func BenchmarkPrintSourceLocation(b *testing.B) {
+	l := New(ioutil.Discard, "", Lshortfile)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Print("test")
+	}
+}
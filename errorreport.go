@@ -0,0 +1,165 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+)
+
+// ServiceName and ServiceVersion identify the service in Cloud Error Reporting, if
+// error reporting is enabled, see SetErrorReporting. The initial values are taken from
+// the environment variables K_SERVICE and K_REVISION set by Cloud Run and Cloud Functions,
+// or GAE_SERVICE and GAE_VERSION set by App Engine. If ServiceName is empty, the
+// serviceContext is omitted and Error Reporting works it out from the monitored resource.
+var (
+	ServiceName    = firstEnv("K_SERVICE", "GAE_SERVICE")
+	ServiceVersion = firstEnv("K_REVISION", "GAE_VERSION")
+)
+
+// reportedErrorEventType is the "@type" of the entries, which Cloud Error Reporting
+// picks up regardless of the content of their message.
+const reportedErrorEventType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// SetErrorReporting enables or disables reporting the entries of severity ERROR and above
+// logged through l to Cloud Error Reporting, which groups them and alerts on them. Such
+// entries get the "@type" of a ReportedErrorEvent, the serviceContext made of ServiceName
+// and ServiceVersion, the context.reportLocation of the caller, and the stack of the
+// calling goroutine in the field "stack_trace". It applies to Error, Critical, Alert,
+// Emergency, Fatal and Panic functions alike. Capturing the stack has a cost, so it is
+// disabled by default. It is safe to call SetErrorReporting while other goroutines are
+// logging.
+//
+// The reportLocation does not depend on SetSourceLocation, though it does honor
+// WithCallerSkip.
+func (l *Logger) SetErrorReporting(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	l.reporting.store(v)
+}
+
+// SetErrorReporting enables or disables reporting the entries of severity ERROR and above
+// logged by the package-level functions to Cloud Error Reporting.
+// See (*Logger).SetErrorReporting.
+func SetErrorReporting(enabled bool) {
+	std.SetErrorReporting(enabled)
+}
+
+// reportLocation is the SourceLocation of a ReportedErrorEvent as described in
+// https://cloud.google.com/error-reporting/reference/rest/v1beta1/ErrorContext#SourceLocation.
+type reportLocation struct {
+	FilePath     string `json:"filePath"`
+	LineNumber   int    `json:"lineNumber"`
+	FunctionName string `json:"functionName"`
+}
+
+type serviceContext struct {
+	Service string `json:"service"`
+	Version string `json:"version,omitempty"`
+}
+
+type errorContext struct {
+	ReportLocation *reportLocation `json:"reportLocation,omitempty"`
+}
+
+type reportedErrorEvent struct {
+	Type           string          `json:"@type"`
+	ServiceContext *serviceContext `json:"serviceContext,omitempty"`
+	Context        *errorContext   `json:"context,omitempty"`
+	StackTrace     string          `json:"stack_trace,omitempty"`
+}
+
+// errorReport returns the encoded JSON object members, without the braces, which report
+// an entry of severity s to Cloud Error Reporting, or nil if l does not report it.
+// The depth is as described for the function log.
+func (l *Logger) errorReport(s Severity, depth int) []byte {
+	if !l.reports(s) {
+		return nil
+	}
+
+	skip := depth + 1 + l.callerSkip
+	var pcs [1]uintptr
+	if runtime.Callers(skip, pcs[:]) == 0 {
+		return nil
+	}
+
+	return encodeErrorReport(pcs[0], stackTrace(skip))
+}
+
+// pcErrorReport is errorReport for the program counter pc as returned by runtime.Callers.
+// There is no stack trace, as the goroutine may have moved on since pc.
+func (l *Logger) pcErrorReport(s Severity, pc uintptr) []byte {
+	if !l.reports(s) {
+		return nil
+	}
+
+	return encodeErrorReport(pc, "")
+}
+
+// reports tells whether the entries of severity s logged through l go to Error Reporting.
+func (l *Logger) reports(s Severity) bool {
+	return s.IsErrorish() && l.load(func(l *Logger) *setting { return &l.reporting }) != 0
+}
+
+func encodeErrorReport(pc uintptr, stack string) []byte {
+	ev := reportedErrorEvent{Type: reportedErrorEventType, StackTrace: stack}
+	if ServiceName != "" {
+		ev.ServiceContext = &serviceContext{Service: ServiceName, Version: ServiceVersion}
+	}
+
+	if pc != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		if frame.File != "" {
+			ev.Context = &errorContext{&reportLocation{frame.File, frame.Line, frame.Function}}
+		}
+	}
+
+	buf, err := marshalJSON(ev)
+	if err != nil {
+		return nil
+	}
+
+	// Remove the braces.
+	return buf[1 : len(buf)-1]
+}
+
+// stackTrace returns the stack of the calling goroutine in the format of runtime.Stack,
+// without the innermost frames up to the user's code, which is runtime.Caller(skip) if
+// called by stackTrace itself.
+func stackTrace(skip int) string {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	// The header line "goroutine N [running]:" is followed by two lines per frame, the
+	// function and the file, the first frame being stackTrace itself.
+	header := bytes.IndexByte(buf, '\n') + 1
+	rest := buf[header:]
+	for i := 0; i < 2*skip; i++ {
+		nl := bytes.IndexByte(rest, '\n')
+		if nl < 0 {
+			break
+		}
+		rest = rest[nl+1:]
+	}
+
+	return string(buf[:header]) + string(bytes.TrimRight(rest, "\n"))
+}
+
+// firstEnv returns the value of the first environment variable, which is not empty.
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,113 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSetErrorReporting(t *testing.T) {
+	ServiceName, ServiceVersion = "my-service", "my-service-00001"
+	defer func() { ServiceName, ServiceVersion = "", "" }()
+
+	tests := []struct {
+		name string
+		log  func(l *Logger)
+	}{
+		{"Error", func(l *Logger) { l.Error("a") }},
+		{"Criticalf", func(l *Logger) { l.Criticalf("%s", "a") }},
+		{"Alertj", func(l *Logger) { l.Alertj("a", map[string]int{"b": 1}) }},
+		{"Emergencyln", func(l *Logger) { l.Emergencyln("a") }},
+		{"With", func(l *Logger) { l.With("b", 1).Error("a") }},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			buf := &bytes.Buffer{}
+			l := New(buf, "", 0)
+			l.SetErrorReporting(true)
+
+			// Act
+			tt.log(l)
+
+			// Assert
+			var got struct {
+				Type           string `json:"@type"`
+				ServiceContext struct {
+					Service string `json:"service"`
+					Version string `json:"version"`
+				} `json:"serviceContext"`
+				Context struct {
+					ReportLocation reportLocation `json:"reportLocation"`
+				} `json:"context"`
+				StackTrace string `json:"stack_trace"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+			}
+			if got.Type != reportedErrorEventType {
+				t.Errorf("unexpected @type %q", got.Type)
+			}
+			if got.ServiceContext.Service != "my-service" || got.ServiceContext.Version != "my-service-00001" {
+				t.Errorf("unexpected serviceContext %+v", got.ServiceContext)
+			}
+			loc := got.Context.ReportLocation
+			if !strings.HasSuffix(loc.FilePath, "errorreport_test.go") || loc.LineNumber == 0 ||
+				!strings.HasPrefix(loc.FunctionName, "github.com/apsystole/log.TestSetErrorReporting.") {
+				t.Errorf("unexpected reportLocation %+v", loc)
+			}
+			lines := strings.Split(got.StackTrace, "\n")
+			if len(lines) < 3 || !strings.HasPrefix(lines[0], "goroutine ") ||
+				!strings.HasPrefix(lines[1], "github.com/apsystole/log.TestSetErrorReporting.") {
+				t.Errorf("unexpected stack_trace:\n%s", got.StackTrace)
+			}
+		})
+	}
+}
+
+func TestSetErrorReportingSkipped(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		log     func(l *Logger)
+	}{
+		{"disabled", false, func(l *Logger) { l.Error("a") }},
+		{"Warning", true, func(l *Logger) { l.Warning("a") }},
+		{"Infoj", true, func(l *Logger) { l.Infoj("a", map[string]int{"b": 1}) }},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			l := New(buf, "", 0)
+			l.SetErrorReporting(tt.enabled)
+
+			tt.log(l)
+
+			if strings.Contains(buf.String(), "@type") {
+				t.Errorf("unexpected error report:\n%s", buf.String())
+			}
+		})
+	}
+}
+
+func TestSetErrorReportingCallerSkip(t *testing.T) {
+	// Arrange
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	l.SetErrorReporting(true)
+	l = l.WithCallerSkip(1)
+
+	// Act
+	func() { l.Error("a") }()
+
+	// Assert
+	if strings.Contains(buf.String(), "serviceContext") {
+		t.Errorf("unexpected serviceContext without ServiceName:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"functionName":"github.com/apsystole/log.TestSetErrorReportingCallerSkip"`) {
+		t.Errorf("unexpected reportLocation:\n%s", buf.String())
+	}
+}
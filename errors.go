@@ -0,0 +1,176 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
+// FieldError is the optional interface of the errors, which carry structured information.
+// Err logs the fields returned by LogFields under the key "fields" of the error.
+type FieldError interface {
+	error
+	LogFields() map[string]interface{}
+}
+
+// StackError is the optional interface of the errors, which carry the stack trace of the
+// place where they were created, as the program counters returned by runtime.Callers.
+// Err logs the stack trace under the key "stack" of the error.
+type StackError interface {
+	error
+	Callers() []uintptr
+}
+
+// maxErrorDepth limits the nesting of the wrapped errors logged by Err, for the sake of
+// the errors that wrap themselves.
+const maxErrorDepth = 32
+
+// errorInfo is the jsonPayload describing an error, see Err.
+type errorInfo struct {
+	Message string                 `json:"message"`
+	Type    string                 `json:"type"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Stack   string                 `json:"stack,omitempty"`
+	Cause   *errorInfo             `json:"cause,omitempty"`
+	Causes  []*errorInfo           `json:"causes,omitempty"`
+}
+
+// Err logs the error err of severity ERROR. The message of the entry is msg followed by
+// a colon and the text of err, or just the text of err if msg is empty. The jsonPayload
+// field "error" describes err as a structure:
+//
+//	"message"  the text of the error
+//	"type"     the Go type of the error, such as "*fs.PathError"
+//	"fields"   the fields of an error implementing FieldError
+//	"stack"    the stack trace of an error implementing StackError
+//	"cause"    the error returned by the method Unwrap() error, described likewise
+//	"causes"   the errors returned by the method Unwrap() []error, as of errors.Join
+//
+// If err is nil, Err logs just msg. If err is a nil pointer, such as (*MyError)(nil), its text
+// is "<nil>", and its methods are not called.
+func (l *Logger) Err(err error, msg string) {
+	logErr(SeverityError, l, 2, err, msg)
+}
+
+// LogErr logs the error err of severity s, which is useful when the severity is computed.
+// See (*Logger).Err for the details.
+func (l *Logger) LogErr(s Severity, err error, msg string) {
+	logErr(s, l, 2, err, msg)
+}
+
+// LogErr logs the error err of severity s, which is useful when the severity is computed.
+// See (*Logger).Err for the details.
+func LogErr(s Severity, err error, msg string) {
+	logErr(s, &std, 2, err, msg)
+}
+
+func logErr(s Severity, l *Logger, depth int, err error, msg string) {
+	if !l.Enabled(s) {
+		return
+	}
+
+	if err == nil {
+		logs(s, l, depth+1, msg)
+
+		return
+	}
+
+	if msg == "" {
+		msg = errorText(err)
+	} else {
+		msg += ": " + errorText(err)
+	}
+
+	buf, merr := marshalJSON(struct {
+		Error *errorInfo `json:"error"`
+	}{newErrorInfo(err, true, 0)})
+	if merr != nil {
+		// Do not include the merr, for the same reasons as in logj. Most likely the fields
+		// of a FieldError are to blame, so log without them.
+		buf, merr = marshalJSON(struct {
+			Error *errorInfo `json:"error"`
+		}{newErrorInfo(err, false, 0)})
+		if merr != nil {
+			buf = []byte(`{"logLibMsg":"cannot marshal the error"}`)
+		}
+	}
+
+	logRawJSON(s, l, depth+1, msg, buf)
+}
+
+// newErrorInfo describes err and the errors it wraps, with or without the fields of
+// FieldError. The depth is the nesting of err.
+func newErrorInfo(err error, fields bool, depth int) *errorInfo {
+	info := &errorInfo{
+		Message: errorText(err),
+		Type:    fmt.Sprintf("%T", err),
+	}
+	if isNilPointer(err) {
+		return info
+	}
+
+	if fe, ok := err.(FieldError); ok && fields {
+		info.Fields = fe.LogFields()
+	}
+
+	if se, ok := err.(StackError); ok {
+		info.Stack = formatCallers(se.Callers())
+	}
+
+	if depth+1 >= maxErrorDepth {
+		return info
+	}
+
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if cause := u.Unwrap(); cause != nil {
+			info.Cause = newErrorInfo(cause, fields, depth+1)
+		}
+	case interface{ Unwrap() []error }:
+		for _, cause := range u.Unwrap() {
+			if cause != nil {
+				info.Causes = append(info.Causes, newErrorInfo(cause, fields, depth+1))
+			}
+		}
+	}
+
+	return info
+}
+
+// errorText returns the text of err, or "<nil>" for a nil pointer, the same as fmt does,
+// as its method Error is likely to panic.
+func errorText(err error) string {
+	if isNilPointer(err) {
+		return "<nil>"
+	}
+
+	return err.Error()
+}
+
+// formatCallers formats the program counters of runtime.Callers as does runtime.Stack,
+// that is two lines per frame: the function, and the file and line indented by a tab.
+func formatCallers(pcs []uintptr) string {
+	if len(pcs) == 0 {
+		return ""
+	}
+
+	var b bytes.Buffer
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" || frame.File != "" {
+			b.WriteString(frame.Function)
+			b.WriteString("()\n\t")
+			b.WriteString(frame.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(frame.Line))
+			b.WriteByte('\n')
+		}
+		if !more {
+			break
+		}
+	}
+
+	return string(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type wrapError struct {
+	msg string
+	err error
+}
+
+func (e *wrapError) Error() string { return e.msg + ": " + e.err.Error() }
+func (e *wrapError) Unwrap() error { return e.err }
+
+type joinError []error
+
+func (e joinError) Error() string   { return "joined" }
+func (e joinError) Unwrap() []error { return e }
+
+type fieldError struct {
+	fields map[string]interface{}
+}
+
+func (e fieldError) Error() string                     { return "field error" }
+func (e fieldError) LogFields() map[string]interface{} { return e.fields }
+
+type stackError struct {
+	pcs []uintptr
+}
+
+func (e stackError) Error() string      { return "stack error" }
+func (e stackError) Callers() []uintptr { return e.pcs }
+
+func TestErr(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		msg      string
+		wantJSON string
+	}{
+		{
+			name:     "nil",
+			err:      nil,
+			msg:      "a",
+			wantJSON: `{"message":"a","severity":"ERROR"}` + "\n",
+		},
+		{
+			name:     "plain",
+			err:      errors.New("b"),
+			msg:      "",
+			wantJSON: `{"message":"b","severity":"ERROR","error":{"message":"b","type":"*errors.errorString"}}` + "\n",
+		},
+		{
+			name: "chain",
+			err:  &wrapError{"c", fieldError{map[string]interface{}{"id": 7}}},
+			msg:  "a",
+			wantJSON: `{"message":"a: c: field error","severity":"ERROR","error":{"message":"c: field error","type":"*log.wrapError",` +
+				`"cause":{"message":"field error","type":"log.fieldError","fields":{"id":7}}}}` + "\n",
+		},
+		{
+			name: "join",
+			err:  joinError{errors.New("d"), nil, &wrapError{"e", errors.New("f")}},
+			msg:  "a",
+			wantJSON: `{"message":"a: joined","severity":"ERROR","error":{"message":"joined","type":"log.joinError","causes":[` +
+				`{"message":"d","type":"*errors.errorString"},` +
+				`{"message":"e: f","type":"*log.wrapError","cause":{"message":"f","type":"*errors.errorString"}}]}}` + "\n",
+		},
+		{
+			name:     "typed nil",
+			err:      (*wrapError)(nil),
+			msg:      "a",
+			wantJSON: `{"message":"a: <nil>","severity":"ERROR","error":{"message":"<nil>","type":"*log.wrapError"}}` + "\n",
+		},
+		{
+			name: "typed nil cause",
+			err:  joinError{(*wrapError)(nil)},
+			msg:  "a",
+			wantJSON: `{"message":"a: joined","severity":"ERROR","error":{"message":"joined","type":"log.joinError","causes":[` +
+				`{"message":"<nil>","type":"*log.wrapError"}]}}` + "\n",
+		},
+		{
+			name: "unmarshalable fields",
+			err:  fieldError{map[string]interface{}{"ch": make(chan int)}},
+			msg:  "a",
+			wantJSON: `{"message":"a: field error","severity":"ERROR","error":{"message":"field error","type":"log.fieldError"}}` +
+				"\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			buf := &bytes.Buffer{}
+			l := New(buf, "", 0)
+
+			// Act
+			l.Err(tt.err, tt.msg)
+
+			// Assert
+			if tt.wantJSON != buf.String() {
+				t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestErrStack(t *testing.T) {
+	// Arrange
+	pcs := make([]uintptr, 1)
+	runtime.Callers(1, pcs)
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+
+	// Act
+	l.LogErr(SeverityWarning, stackError{pcs}, "a")
+
+	// Assert
+	want := `"stack":"github.com/apsystole/log.TestErrStack()\n\t`
+	if !strings.Contains(buf.String(), want) || !strings.Contains(buf.String(), `"severity":"WARNING"`) {
+		t.Errorf("unexpected output, got:\n%q\nexpected to contain:\n%q\n", buf.String(), want)
+	}
+}
+
+func TestErrBelowLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	l.SetLevel(SeverityCritical)
+
+	l.Err(errors.New("a"), "b")
+
+	if buf.Len() != 0 {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,124 @@
+package log
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// exitConfig is what Exit does, see SetExitFunc and SetExitCode.
+type exitConfig struct {
+	fn   func(code int)
+	code int
+}
+
+var (
+	// exitMu serializes the changes of the exitConfig of any Logger.
+	exitMu sync.Mutex
+
+	exitHooksMu sync.Mutex
+	exitHooks   []func()
+)
+
+// AtExit registers the function f to be run by Exit, and so by the Fatal functions, before
+// the program exits, e.g. to flush buffered output or to close files. The functions run in
+// the reverse order of their registration, like the deferred calls. A panic in one of them
+// is ignored, so that the others still run. It is safe to call AtExit concurrently.
+func AtExit(f func()) {
+	exitHooksMu.Lock()
+	defer exitHooksMu.Unlock()
+
+	exitHooks = append(exitHooks, f)
+}
+
+// runExitHooks runs the functions registered by AtExit.
+func runExitHooks() {
+	exitHooksMu.Lock()
+	hooks := make([]func(), len(exitHooks))
+	copy(hooks, exitHooks)
+	exitHooksMu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		func() {
+			defer func() { _ = recover() }()
+			hooks[i]()
+		}()
+	}
+}
+
+// SetExitFunc sets the function, which Exit of l calls to end the program, instead of
+// os.Exit. Tests can set it to record the exit code and return, in which case the Fatal
+// functions return too. Setting it to nil restores os.Exit. Children created by With and
+// alike inherit the exit function of l, until it is set on them.
+func (l *Logger) SetExitFunc(f func(code int)) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+
+	c := l.exitConfig()
+	l.exit.Store(&exitConfig{fn: f, code: c.code})
+}
+
+// SetExitCode sets the exit code used by Exit of l, which is 1 by default.
+func (l *Logger) SetExitCode(code int) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+
+	c := l.exitConfig()
+	l.exit.Store(&exitConfig{fn: c.fn, code: code})
+}
+
+// ExitCode returns the exit code used by Exit of l.
+func (l *Logger) ExitCode() int {
+	return l.exitConfig().code
+}
+
+// Exit flushes l, see Flush, runs the functions registered by AtExit, and then exits the
+// program with the exit code of l, see SetExitCode, through the exit function of l, see
+// SetExitFunc. The Fatal functions of l call Exit after logging.
+func (l *Logger) Exit() {
+	l.exitWith(l.ExitCode())
+}
+
+// SetExitFunc sets the function, which the package-level Exit calls to end the program,
+// instead of os.Exit. See (*Logger).SetExitFunc.
+func SetExitFunc(f func(code int)) {
+	std.SetExitFunc(f)
+}
+
+// SetExitCode sets the exit code used by the package-level Exit, which is 1 by default.
+func SetExitCode(code int) {
+	std.SetExitCode(code)
+}
+
+// ExitCode returns the exit code used by the package-level Exit.
+func ExitCode() int {
+	return std.ExitCode()
+}
+
+// Exit flushes the package-level logger, runs the functions registered by AtExit, and then
+// exits the program, as configured by SetExitFunc and SetExitCode. The package-level Fatal
+// functions call Exit after logging.
+func Exit() {
+	std.Exit()
+}
+
+// exitConfig returns the exitConfig of l, the default one if it was never set.
+func (l *Logger) exitConfig() exitConfig {
+	if c, ok := l.loadValue(func(l *Logger) *atomic.Value { return &l.exit }).(*exitConfig); ok {
+		return *c
+	}
+
+	return exitConfig{code: 1}
+}
+
+// exitWith is Exit with the exit code given.
+func (l *Logger) exitWith(code int) {
+	_ = l.Flush()
+	runExitHooks()
+
+	fn := l.exitConfig().fn
+	if fn == nil {
+		fn = os.Exit
+	}
+	fn(code)
+}
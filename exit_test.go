@@ -0,0 +1,55 @@
+package log
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestLogger_FatalExit(t *testing.T) {
+	// Arrange
+	defer func(hooks []func()) { exitHooks = hooks }(exitHooks)
+	exitHooks = nil
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	var got []string
+	l.SetExitFunc(func(code int) {
+		got = append(got, "exit "+strconv.Itoa(code))
+	})
+	AtExit(func() { got = append(got, "first hook, logged: "+buf.String()) })
+	AtExit(func() { panic("ignored") })
+	AtExit(func() { got = append(got, "second hook") })
+
+	// Act
+	l.Fatalf("bye %d", 1)
+	l.SetExitCode(3)
+	l.With("a", 1).Fatal()
+
+	// Assert
+	want := []string{
+		"second hook",
+		"first hook, logged: " + `{"message":"bye 1","severity":"CRITICAL"}` + "\n",
+		"exit 1",
+		"second hook",
+		"first hook, logged: " + `{"message":"bye 1","severity":"CRITICAL"}` + "\n" + `{"message":"","severity":"CRITICAL","a":1}` + "\n",
+		"exit 3",
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("unexpected calls, got:\n%q\nexpected:\n%q\n", got, want)
+	}
+}
+
+func TestLogger_ExitCode(t *testing.T) {
+	l := New(&bytes.Buffer{}, "", 0)
+	if l.ExitCode() != 1 {
+		t.Errorf("default exit code = %d, want 1", l.ExitCode())
+	}
+
+	l.SetExitCode(4)
+	l.SetExitFunc(func(int) {})
+
+	if l.ExitCode() != 4 {
+		t.Errorf("exit code = %d, want 4", l.ExitCode())
+	}
+}
@@ -0,0 +1,378 @@
+package log
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Field is a key and a typed value, which becomes a field of the jsonPayload. The fields
+// are encoded directly into the entry, without reflection, except those created by Any.
+// Create them with the functions like String or Int64, and log them with the methods like
+// (*Logger).InfoFields, or pass them among the key-value pairs of Infow or With.
+// The zero Field is omitted.
+type Field struct {
+	key  string
+	kind fieldKind
+	num  uint64
+	str  string
+	val  interface{}
+}
+
+type fieldKind uint8
+
+const (
+	skipKind fieldKind = iota
+	stringKind
+	int64Kind
+	uint64Kind
+	float64Kind
+	boolKind
+	durationKind
+	timeKind
+	errorKind
+	stringerKind
+	objectKind
+	arrayKind
+	anyKind
+)
+
+// ObjectMarshaler is implemented by the types, which encode themselves as JSON objects
+// without reflection, see Object.
+type ObjectMarshaler interface {
+	MarshalLogObject(enc *ObjectEncoder)
+}
+
+// ArrayMarshaler is implemented by the types, which encode themselves as JSON arrays
+// without reflection, see Array.
+type ArrayMarshaler interface {
+	MarshalLogArray(enc *ArrayEncoder)
+}
+
+// String returns a Field with a string value.
+func String(key, v string) Field {
+	return Field{key: key, kind: stringKind, str: v}
+}
+
+// Int returns a Field with an int value.
+func Int(key string, v int) Field {
+	return Int64(key, int64(v))
+}
+
+// Int64 returns a Field with an int64 value.
+func Int64(key string, v int64) Field {
+	return Field{key: key, kind: int64Kind, num: uint64(v)}
+}
+
+// Uint64 returns a Field with a uint64 value.
+func Uint64(key string, v uint64) Field {
+	return Field{key: key, kind: uint64Kind, num: v}
+}
+
+// Float64 returns a Field with a float64 value. The NaN and the infinities become strings.
+func Float64(key string, v float64) Field {
+	return Field{key: key, kind: float64Kind, num: math.Float64bits(v)}
+}
+
+// Bool returns a Field with a bool value.
+func Bool(key string, v bool) Field {
+	var n uint64
+	if v {
+		n = 1
+	}
+
+	return Field{key: key, kind: boolKind, num: n}
+}
+
+// Duration returns a Field with a time.Duration value, encoded as a string such as "1.5s",
+// the format of the Duration of the Cloud Logging API.
+func Duration(key string, v time.Duration) Field {
+	return Field{key: key, kind: durationKind, num: uint64(v)}
+}
+
+// Time returns a Field with a time.Time value, encoded as an RFC 3339 string.
+func Time(key string, v time.Time) Field {
+	return Field{key: key, kind: timeKind, val: v}
+}
+
+// Err returns a Field with the key "error" and the message of err, or null if err is nil
+// or a nil pointer. See (*Logger).Err for logging the structure of an error.
+func Err(err error) Field {
+	return NamedErr("error", err)
+}
+
+// NamedErr returns a Field with the message of err, or null if err is nil or a nil pointer.
+func NamedErr(key string, err error) Field {
+	return Field{key: key, kind: errorKind, val: err}
+}
+
+// Stringer returns a Field with the string returned by v.String(), or null if v is nil
+// or a nil pointer. The method String is called only if the entry is logged.
+func Stringer(key string, v fmt.Stringer) Field {
+	return Field{key: key, kind: stringerKind, val: v}
+}
+
+// Object returns a Field with a JSON object encoded by v, or null if v is nil or a nil pointer.
+func Object(key string, v ObjectMarshaler) Field {
+	return Field{key: key, kind: objectKind, val: v}
+}
+
+// Array returns a Field with a JSON array encoded by v, or null if v is nil or a nil pointer.
+func Array(key string, v ArrayMarshaler) Field {
+	return Field{key: key, kind: arrayKind, val: v}
+}
+
+// Any returns a Field with any value. The common types are encoded directly, as described
+// for (*Logger).Logw, and the rest are marshaled to JSON.
+func Any(key string, v interface{}) Field {
+	return Field{key: key, kind: anyKind, val: v}
+}
+
+// appendMember appends the Field as a JSON object member, preceded by a comma if needed.
+func (f Field) appendMember(buf []byte) []byte {
+	if f.kind == skipKind {
+		return buf
+	}
+
+	buf = appendComma(buf)
+	buf = appendJSONString(buf, f.key)
+	buf = append(buf, ':')
+
+	return f.appendValue(buf)
+}
+
+// appendValue appends the JSON encoding of the value of the Field.
+func (f Field) appendValue(buf []byte) []byte {
+	switch f.kind {
+	case stringKind:
+		return appendJSONString(buf, f.str)
+	case int64Kind:
+		return strconv.AppendInt(buf, int64(f.num), 10)
+	case uint64Kind:
+		return strconv.AppendUint(buf, f.num, 10)
+	case float64Kind:
+		return appendJSONFloat(buf, math.Float64frombits(f.num), 64)
+	case boolKind:
+		return strconv.AppendBool(buf, f.num != 0)
+	case durationKind:
+		return appendJSONString(buf, formatDuration(time.Duration(f.num)))
+	case timeKind:
+		return appendJSONValue(buf, f.val)
+	case errorKind:
+		if err, ok := f.val.(error); ok && err != nil && !isNilPointer(err) {
+			return appendJSONString(buf, err.Error())
+		}
+	case stringerKind:
+		if s, ok := f.val.(fmt.Stringer); ok && s != nil && !isNilPointer(s) {
+			return appendJSONString(buf, s.String())
+		}
+	case objectKind:
+		if m, ok := f.val.(ObjectMarshaler); ok && m != nil && !isNilPointer(m) {
+			enc := ObjectEncoder{buf: append(buf, '{')}
+			m.MarshalLogObject(&enc)
+			return append(enc.buf, '}')
+		}
+	case arrayKind:
+		if m, ok := f.val.(ArrayMarshaler); ok && m != nil && !isNilPointer(m) {
+			enc := ArrayEncoder{buf: append(buf, '[')}
+			m.MarshalLogArray(&enc)
+			return append(enc.buf, ']')
+		}
+	case anyKind:
+		return appendJSONValue(buf, f.val)
+	}
+
+	return append(buf, "null"...)
+}
+
+// ObjectEncoder encodes the members of a JSON object, see ObjectMarshaler.
+type ObjectEncoder struct {
+	buf []byte
+}
+
+// Add adds the fields to the object.
+func (e *ObjectEncoder) Add(fields ...Field) {
+	for _, f := range fields {
+		e.buf = f.appendMember(e.buf)
+	}
+}
+
+// ArrayEncoder encodes the elements of a JSON array, see ArrayMarshaler.
+type ArrayEncoder struct {
+	buf []byte
+}
+
+// AppendString appends a string element.
+func (e *ArrayEncoder) AppendString(v string) {
+	e.appendValue(String("", v))
+}
+
+// AppendInt64 appends an integer element.
+func (e *ArrayEncoder) AppendInt64(v int64) {
+	e.appendValue(Int64("", v))
+}
+
+// AppendUint64 appends an unsigned integer element.
+func (e *ArrayEncoder) AppendUint64(v uint64) {
+	e.appendValue(Uint64("", v))
+}
+
+// AppendFloat64 appends a floating-point element, see Float64.
+func (e *ArrayEncoder) AppendFloat64(v float64) {
+	e.appendValue(Float64("", v))
+}
+
+// AppendBool appends a bool element.
+func (e *ArrayEncoder) AppendBool(v bool) {
+	e.appendValue(Bool("", v))
+}
+
+// AppendObject appends an object element, see Object.
+func (e *ArrayEncoder) AppendObject(v ObjectMarshaler) {
+	e.appendValue(Object("", v))
+}
+
+// AppendArray appends an array element, see Array.
+func (e *ArrayEncoder) AppendArray(v ArrayMarshaler) {
+	e.appendValue(Array("", v))
+}
+
+// AppendField appends the value of the Field as an element, ignoring its key.
+func (e *ArrayEncoder) AppendField(f Field) {
+	if f.kind == skipKind {
+		return
+	}
+	e.appendValue(f)
+}
+
+func (e *ArrayEncoder) appendValue(f Field) {
+	if len(e.buf) != 0 && e.buf[len(e.buf)-1] != '[' {
+		e.buf = append(e.buf, ',')
+	}
+	e.buf = f.appendValue(e.buf)
+}
+
+// DebugFields logs detailed information that could mainly be used to catch unforeseen problems.
+// The fields become the fields of the jsonPayload, see Field.
+func DebugFields(msg string, fields ...Field) {
+	logFields(SeverityDebug, &std, 2, msg, fields)
+}
+
+// InfoFields logs routine information, such as ongoing status or performance.
+// The fields become the fields of the jsonPayload, see Field.
+func InfoFields(msg string, fields ...Field) {
+	logFields(SeverityInfo, &std, 2, msg, fields)
+}
+
+// NoticeFields logs normal but significant events, such as start up, shut down, or configuration.
+// The fields become the fields of the jsonPayload, see Field.
+func NoticeFields(msg string, fields ...Field) {
+	logFields(SeverityNotice, &std, 2, msg, fields)
+}
+
+// WarningFields logs events that might cause problems.
+// The fields become the fields of the jsonPayload, see Field.
+func WarningFields(msg string, fields ...Field) {
+	logFields(SeverityWarning, &std, 2, msg, fields)
+}
+
+// ErrorFields logs events likely to cause problems.
+// The fields become the fields of the jsonPayload, see Field.
+func ErrorFields(msg string, fields ...Field) {
+	logFields(SeverityError, &std, 2, msg, fields)
+}
+
+// CriticalFields logs events that cause more severe problems or outages.
+// The fields become the fields of the jsonPayload, see Field.
+func CriticalFields(msg string, fields ...Field) {
+	logFields(SeverityCritical, &std, 2, msg, fields)
+}
+
+// AlertFields logs when a person must take an action immediately.
+// The fields become the fields of the jsonPayload, see Field.
+func AlertFields(msg string, fields ...Field) {
+	logFields(SeverityAlert, &std, 2, msg, fields)
+}
+
+// EmergencyFields logs when one or more systems are unusable.
+// The fields become the fields of the jsonPayload, see Field.
+func EmergencyFields(msg string, fields ...Field) {
+	logFields(SeverityEmergency, &std, 2, msg, fields)
+}
+
+// LogFields logs a message of severity s, which is useful when the severity is computed.
+// The fields become the fields of the jsonPayload, see Field.
+func LogFields(s Severity, msg string, fields ...Field) {
+	logFields(s, &std, 2, msg, fields)
+}
+
+// DebugFields logs detailed information that could mainly be used to catch unforeseen problems.
+// The fields become the fields of the jsonPayload, see Field.
+func (l *Logger) DebugFields(msg string, fields ...Field) {
+	logFields(SeverityDebug, l, 2, msg, fields)
+}
+
+// InfoFields logs routine information, such as ongoing status or performance.
+// The fields become the fields of the jsonPayload, see Field.
+func (l *Logger) InfoFields(msg string, fields ...Field) {
+	logFields(SeverityInfo, l, 2, msg, fields)
+}
+
+// NoticeFields logs normal but significant events, such as start up, shut down, or configuration.
+// The fields become the fields of the jsonPayload, see Field.
+func (l *Logger) NoticeFields(msg string, fields ...Field) {
+	logFields(SeverityNotice, l, 2, msg, fields)
+}
+
+// WarningFields logs events that might cause problems.
+// The fields become the fields of the jsonPayload, see Field.
+func (l *Logger) WarningFields(msg string, fields ...Field) {
+	logFields(SeverityWarning, l, 2, msg, fields)
+}
+
+// ErrorFields logs events likely to cause problems.
+// The fields become the fields of the jsonPayload, see Field.
+func (l *Logger) ErrorFields(msg string, fields ...Field) {
+	logFields(SeverityError, l, 2, msg, fields)
+}
+
+// CriticalFields logs events that cause more severe problems or outages.
+// The fields become the fields of the jsonPayload, see Field.
+func (l *Logger) CriticalFields(msg string, fields ...Field) {
+	logFields(SeverityCritical, l, 2, msg, fields)
+}
+
+// AlertFields logs when a person must take an action immediately.
+// The fields become the fields of the jsonPayload, see Field.
+func (l *Logger) AlertFields(msg string, fields ...Field) {
+	logFields(SeverityAlert, l, 2, msg, fields)
+}
+
+// EmergencyFields logs when one or more systems are unusable.
+// The fields become the fields of the jsonPayload, see Field.
+func (l *Logger) EmergencyFields(msg string, fields ...Field) {
+	logFields(SeverityEmergency, l, 2, msg, fields)
+}
+
+// LogFields logs a message of severity s, which is useful when the severity is computed.
+// The fields become the fields of the jsonPayload, see Field.
+func (l *Logger) LogFields(s Severity, msg string, fields ...Field) {
+	logFields(s, l, 2, msg, fields)
+}
+
+func logFields(s Severity, l *Logger, depth int, msg string, fields []Field) {
+	if !l.Enabled(s) {
+		return
+	}
+
+	b := getBuffer()
+	*b = append(*b, '{')
+	for _, f := range fields {
+		*b = f.appendMember(*b)
+	}
+	*b = append(*b, '}')
+
+	logRawJSON(s, l, depth+1, msg, *b)
+	putBuffer(b)
+}
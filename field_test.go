@@ -0,0 +1,143 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+type user struct {
+	name  string
+	roles []string
+}
+
+func (u user) MarshalLogObject(enc *ObjectEncoder) {
+	enc.Add(String("name", u.name), Array("roles", roles(u.roles)))
+}
+
+type roles []string
+
+func (r roles) MarshalLogArray(enc *ArrayEncoder) {
+	for _, s := range r {
+		enc.AppendString(s)
+	}
+}
+
+type mixed struct{}
+
+func (mixed) MarshalLogArray(enc *ArrayEncoder) {
+	enc.AppendInt64(-1)
+	enc.AppendUint64(2)
+	enc.AppendFloat64(0.5)
+	enc.AppendBool(false)
+	enc.AppendObject(user{name: "b"})
+	enc.AppendArray(roles{})
+	enc.AppendField(Field{})
+	enc.AppendField(Duration("ignored", time.Second))
+}
+
+func TestLogger_LogFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []Field
+		wantJSON string
+	}{
+		{
+			name:     "none",
+			fields:   nil,
+			wantJSON: `{"message":"m","severity":"INFO"}` + "\n",
+		},
+		{
+			name: "scalars",
+			fields: []Field{
+				String("s", "a\"b"), Int("i", -1), Int64("i64", math.MinInt64), Uint64("u64", math.MaxUint64),
+				Float64("f", 2.5), Float64("inf", math.Inf(1)), Bool("t", true), Bool("f", false),
+				Duration("d", -1500*time.Millisecond), Time("tm", time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)),
+				{},
+			},
+			wantJSON: `{"message":"m","severity":"INFO","s":"a\"b","i":-1,"i64":-9223372036854775808,"u64":18446744073709551615,` +
+				`"f":2.5,"inf":"+Inf","t":true,"f":false,"d":"-1.5s","tm":"2021-03-04T05:06:07Z"}` + "\n",
+		},
+		{
+			name: "interfaces",
+			fields: []Field{
+				Err(errors.New("boom")), NamedErr("cause", nil), Stringer("ip", net.IPv4(10, 0, 0, 1)), Stringer("nil", nil),
+				Any("map", map[string]int{"a": 1}),
+			},
+			wantJSON: `{"message":"m","severity":"INFO","error":"boom","cause":null,"ip":"10.0.0.1","nil":null,"map":{"a":1}}` + "\n",
+		},
+		{
+			name:   "object and array",
+			fields: []Field{Object("user", user{"a", []string{"admin", "dev"}}), Array("mixed", mixed{}), Object("nil", nil)},
+			wantJSON: `{"message":"m","severity":"INFO","user":{"name":"a","roles":["admin","dev"]},` +
+				`"mixed":[-1,2,0.5,false,{"name":"b","roles":[]},[],"1s"],"nil":null}` + "\n",
+		},
+		{
+			name: "typed nil",
+			fields: []Field{
+				Err((*ptrError)(nil)), NamedErr("cause", (*ptrError)(nil)), Stringer("s", (*ptrStringer)(nil)),
+				Object("user", (*user)(nil)), Array("mixed", (*mixed)(nil)),
+			},
+			wantJSON: `{"message":"m","severity":"INFO","error":null,"cause":null,"s":null,"user":null,"mixed":null}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			buf := &bytes.Buffer{}
+			l := New(buf, "", 0)
+
+			// Act
+			l.LogFields(SeverityInfo, "m", tt.fields...)
+
+			// Assert
+			if tt.wantJSON != buf.String() {
+				t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestFieldsInKeyValues(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"m","severity":"NOTICE","a":1,"b":"c","d":2}` + "\n"
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0).With(Int("a", 1))
+
+	// Act
+	l.Noticew("m", String("b", "c"), "d", 2)
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), wantJSON)
+	}
+}
+
+func TestInfoFieldsAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items at random under the race detector")
+	}
+
+	l := New(ioutil.Discard, "", 0)
+
+	got := testing.AllocsPerRun(100, func() {
+		l.InfoFields("test", String("user", "alice"), Int("attempt", 3), Bool("ok", true), Float64("ratio", 0.5))
+	})
+
+	if got != 0 {
+		t.Errorf("%v allocations per entry, want 0", got)
+	}
+}
+
+func BenchmarkInfoFields(b *testing.B) {
+	l := New(ioutil.Discard, "", 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.InfoFields("test", String("user", "alice"), Int("attempt", i), Bool("ok", true), Duration("took", time.Second))
+	}
+}
@@ -0,0 +1,248 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Format is the output format of a Logger, see SetFormat.
+type Format int32
+
+const (
+	// FormatAuto is the default: FormatConsole when writing to a terminal in local
+	// development, and FormatJSON otherwise. See SetFormat for the details.
+	FormatAuto Format = iota
+	// FormatJSON writes the one-line JSON entries understood by Cloud Logging, see
+	// JSONEncoder.
+	FormatJSON
+	// FormatConsole writes human-readable lines: the time, the coloured severity, the
+	// message, the fields of the jsonPayload as key=value pairs, and the trace, see
+	// ConsoleEncoder.
+	FormatConsole
+	// FormatLogfmt writes the entries in logfmt, such as the Loki stack prefers, with
+	// the nested fields flattened to dotted keys, see LogfmtEncoder.
+	FormatLogfmt
+)
+
+// formatEnv is the environment variable, which overrides FormatAuto.
+const formatEnv = "LOG_FORMAT"
+
+// autoFormat is what FormatAuto stands for when writing to a terminal, and envFormat is
+// what it stands for regardless of the writer, if set by the environment variable.
+var autoFormat, envFormat = detectFormat()
+
+// stdoutTerminal and stderrTerminal tell if os.Stdout and os.Stderr are terminals.
+var stdoutTerminal, stderrTerminal = isTerminal(os.Stdout), isTerminal(os.Stderr)
+
+// SetFormat sets the output format of l. It is safe to call SetFormat while other
+// goroutines are logging. Children created by With and alike inherit the format of l,
+// until it is set on them.
+//
+// The default FormatAuto selects FormatConsole when l writes to os.Stdout or os.Stderr,
+// which is a terminal, and none of the environment variables K_SERVICE, GAE_ENV and
+// FUNCTION_TARGET is set, meaning the program does not run in Google Cloud. Otherwise it
+// selects FormatJSON. The environment variable LOG_FORMAT set to "json", "console" or
+// "logfmt" overrides the selection of FormatAuto for every Logger, whatever its writer.
+// The environment variable NO_COLOR disables the colours of FormatConsole.
+//
+// An Encoder set by SetEncoder takes precedence over the format.
+func (l *Logger) SetFormat(f Format) {
+	l.format.store(int32(f))
+}
+
+// Format returns the output format of l, as set by SetFormat.
+func (l *Logger) Format() Format {
+	return Format(l.load(func(l *Logger) *setting { return &l.format }))
+}
+
+// SetFormat sets the output format of the package-level logger. See (*Logger).SetFormat.
+func SetFormat(f Format) {
+	std.SetFormat(f)
+}
+
+// SetEncoder sets the Encoder of the entries written through l, which overrides
+// the Format, see SetFormat. Setting it to nil restores the Encoder of the Format.
+// It is safe to call SetEncoder while other goroutines are logging. Children created
+// by With and alike inherit the Encoder of l, until it is set on them.
+func (l *Logger) SetEncoder(enc Encoder) {
+	l.encoder.Store(encoderValue{enc})
+}
+
+// Encoder returns the Encoder of l set by SetEncoder, or nil.
+func (l *Logger) Encoder() Encoder {
+	v, _ := l.loadValue(func(l *Logger) *atomic.Value { return &l.encoder }).(encoderValue)
+
+	return v.enc
+}
+
+// SetEncoder sets the Encoder of the package-level logger. See (*Logger).SetEncoder.
+func SetEncoder(enc Encoder) {
+	std.SetEncoder(enc)
+}
+
+// encoderValue is the Encoder stored by SetEncoder, as atomic.Value does not store nil,
+// nor the values of different types.
+type encoderValue struct {
+	enc Encoder
+}
+
+// encoderFor returns the Encoder of the entries written through l to w. If w is nil, it
+// returns nil, unless the Encoder does not depend on the writer.
+func (l *Logger) encoderFor(w io.Writer) Encoder {
+	if enc := l.Encoder(); enc != nil {
+		return enc
+	}
+
+	return formatEncoder(l.outputFormat(w))
+}
+
+// formatEncoder returns the Encoder of the format f, or nil for FormatAuto.
+func formatEncoder(f Format) Encoder {
+	switch f {
+	case FormatAuto:
+		return nil
+	case FormatConsole:
+		return ConsoleEncoder{}
+	case FormatLogfmt:
+		return LogfmtEncoder{}
+	default:
+		return JSONEncoder{}
+	}
+}
+
+// outputFormat returns the format of the entries written through l to w, see
+// resolveFormat.
+func (l *Logger) outputFormat(w io.Writer) Format {
+	return resolveFormat(l.Format(), w)
+}
+
+// resolveFormat returns the format f of the entries written to w, with FormatAuto
+// resolved. If w is nil, it returns FormatAuto, unless the format does not depend on
+// the writer.
+func resolveFormat(f Format, w io.Writer) Format {
+	if f != FormatAuto {
+		return f
+	}
+	if envFormat != FormatAuto {
+		return envFormat
+	}
+	if autoFormat == FormatJSON || !stdoutTerminal && !stderrTerminal {
+		return FormatJSON
+	}
+	if w == nil {
+		return FormatAuto
+	}
+
+	if w == os.Stdout && stdoutTerminal || w == os.Stderr && stderrTerminal {
+		return autoFormat
+	}
+
+	return FormatJSON
+}
+
+// detectFormat returns the format selected by FormatAuto for the terminals, and the one
+// selected for any writer by the environment variable LOG_FORMAT, if set.
+func detectFormat() (Format, Format) {
+	switch strings.ToLower(os.Getenv(formatEnv)) {
+	case "json":
+		return FormatJSON, FormatJSON
+	case "console":
+		return FormatConsole, FormatConsole
+	case "logfmt":
+		return FormatLogfmt, FormatLogfmt
+	}
+
+	for _, k := range []string{"K_SERVICE", "GAE_ENV", "FUNCTION_TARGET"} {
+		if os.Getenv(k) != "" {
+			return FormatJSON, FormatAuto
+		}
+	}
+
+	return FormatConsole, FormatAuto
+}
+
+// isTerminal tells if f is a character device, such as a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+type member struct {
+	key   string
+	value json.RawMessage
+}
+
+// entryFields returns the labels, the HTTP request and the Fields of e as the members of
+// a JSON object, in this order, for the Encoders, which flatten them.
+func entryFields(e *Entry) []member {
+	var fields []member
+	if len(e.Labels) != 0 {
+		fields = append(fields, member{"labels", appendLabels(nil, e.Labels)})
+	}
+	if e.HTTPRequest != nil {
+		fields = append(fields, member{"httpRequest", appendJSONValue(nil, e.HTTPRequest)})
+	}
+
+	if f := bytes.TrimSpace(e.Fields); len(f) != 0 {
+		n := len(fields)
+		ok := f[0] == '{' && forEachMember(f, func(key string, raw json.RawMessage) bool {
+			fields = append(fields, member{key, raw})
+			return true
+		})
+		if !ok {
+			fields = append(fields[:n], member{"value", f})
+		}
+	}
+
+	return fields
+}
+
+// forEachMember calls fn for the members of the JSON object in src, in their order,
+// until fn returns false. It reports whether src is an object, and fn returned true.
+func forEachMember(src []byte, fn func(key string, value json.RawMessage) bool) bool {
+	dec := json.NewDecoder(bytes.NewReader(src))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return false
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		key, _ := t.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return false
+		}
+
+		if !fn(key, raw) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// needsQuoting tells whether the string value s must be quoted in a key=value pair.
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+
+	return false
+}
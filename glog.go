@@ -6,7 +6,8 @@
 // These severity levels are: DEBUG, INFO, NOTICE, WARNING, ERROR, CRITICAL, ALERT, EMERGENCY.
 //
 // The ERROR, CRITICAL, ALERT, EMERGENCY logs are written to the standard error stream, while
-// the remaining logs are written to the standard output.
+// the remaining logs are written to the standard output. The messages of a severity
+// lower than the one set by SetLevel are discarded.
 package log
 
 import (
@@ -16,8 +17,9 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var std Logger
@@ -27,667 +29,981 @@ var std Logger
 // environment variable GOOGLE_CLOUD_PROJECT.
 var ProjectID = os.Getenv("GOOGLE_CLOUD_PROJECT")
 
+// SetLevel sets the minimum severity of the messages logged by the package-level
+// functions, such as Debug or Info. Messages of a lower severity are discarded before
+// their arguments are formatted or marshaled. By default all messages are logged.
+// It is safe to call SetLevel while other goroutines are logging.
+func SetLevel(s Severity) {
+	std.SetLevel(s)
+}
+
+// Level returns the minimum severity of the messages logged by the package-level functions.
+func Level() Severity {
+	return std.Level()
+}
+
+// With returns a child of the package-level logger, which adds the key-value pairs
+// to the jsonPayload of every log entry. See (*Logger).With for the details.
+func With(kv ...interface{}) *Logger {
+	return std.With(kv...)
+}
+
+// WithFields returns a child of the package-level logger, which adds the fields of v
+// to the jsonPayload of every log entry. See (*Logger).WithFields for the details.
+func WithFields(v interface{}) *Logger {
+	return std.WithFields(v)
+}
+
+// WithLabels returns a child of the package-level logger, which adds the labels to every
+// log entry. See (*Logger).WithLabels for the details.
+func WithLabels(labels map[string]string) *Logger {
+	return std.WithLabels(labels)
+}
+
 // Debug logs detailed information that could mainly be used to catch unforeseen problems.
 // Arguments are handled in the manner of fmt.Print.
 func Debug(v ...interface{}) {
-	std.Debug(v...)
+	log(SeverityDebug, &std, 2, v...)
 }
 
 // Debugln logs detailed information that could mainly be used to catch unforeseen problems.
 // Arguments are handled in the manner of fmt.Println.
 func Debugln(v ...interface{}) {
-	std.Debugln(v...)
+	logln(SeverityDebug, &std, 2, v...)
 }
 
 // Debugf logs detailed information that could mainly be used to catch unforeseen problems.
 // Arguments are handled in the manner of fmt.Printf.
 func Debugf(format string, v ...interface{}) {
-	std.Debugf(format, v...)
+	logf(SeverityDebug, &std, 2, format, v...)
 }
 
 // Debugj logs detailed information that could mainly be used to catch unforeseen problems.
 // Argument v becomes jsonPayload field in the log entry.
 func Debugj(msg string, v interface{}) {
-	std.Debugj(msg, v)
+	logj(SeverityDebug, &std, 2, msg, v)
 }
 
 // Info logs routine information, such as ongoing status or performance.
 // Arguments are handled in the manner of fmt.Print.
 func Info(v ...interface{}) {
-	std.Info(v...)
+	log(SeverityInfo, &std, 2, v...)
 }
 
 // Infoln logs routine information, such as ongoing status or performance.
 // Arguments are handled in the manner of fmt.Println.
 func Infoln(v ...interface{}) {
-	std.Infoln(v...)
+	logln(SeverityInfo, &std, 2, v...)
 }
 
 // Infof logs routine information, such as ongoing status or performance.
 // Arguments are handled in the manner of fmt.Printf.
 func Infof(format string, v ...interface{}) {
-	std.Infof(format, v...)
+	logf(SeverityInfo, &std, 2, format, v...)
 }
 
 // Infoj logs routine information, such as ongoing status or performance.
 // Argument v becomes the jsonPayload field of the log entry.
 func Infoj(msg string, v interface{}) {
-	std.Infoj(msg, v)
+	logj(SeverityInfo, &std, 2, msg, v)
 }
 
 // Notice logs normal but significant events, such as start up, shut down, or configuration.
 // Arguments are handled in the manner of fmt.Print.
 func Notice(v ...interface{}) {
-	std.Notice(v...)
+	log(SeverityNotice, &std, 2, v...)
 }
 
 // Noticeln logs normal but significant events, such as start up, shut down, or configuration.
 // Arguments are handled in the manner of fmt.Println.
 func Noticeln(v ...interface{}) {
-	std.Noticeln(v...)
+	logln(SeverityNotice, &std, 2, v...)
 }
 
 // Noticef logs normal but significant events, such as start up, shut down, or configuration.
 // Arguments are handled in the manner of fmt.Printf.
 func Noticef(format string, v ...interface{}) {
-	std.Noticef(format, v...)
+	logf(SeverityNotice, &std, 2, format, v...)
 }
 
 // Noticej logs normal but significant events, such as start up, shut down, or configuration.
 // Argument v becomes the jsonPayload field of the log entry.
 func Noticej(msg string, v interface{}) {
-	std.Noticej(msg, v)
+	logj(SeverityNotice, &std, 2, msg, v)
 }
 
 // Warning logs events that might cause problems.
 // Arguments are handled in the manner of fmt.Print.
 func Warning(v ...interface{}) {
-	std.Warning(v...)
+	log(SeverityWarning, &std, 2, v...)
 }
 
 // Warningln logs events that might cause problems.
 // Arguments are handled in the manner of fmt.Println.
 func Warningln(v ...interface{}) {
-	std.Warningln(v...)
+	logln(SeverityWarning, &std, 2, v...)
 }
 
 // Warningf logs events that might cause problems.
 // Arguments are handled in the manner of fmt.Printf.
 func Warningf(format string, v ...interface{}) {
-	std.Warningf(format, v...)
+	logf(SeverityWarning, &std, 2, format, v...)
 }
 
 // Warningj logs events that might cause problems.
 // Argument v becomes the jsonPayload field of the log entry.
 func Warningj(msg string, v interface{}) {
-	std.Warningj(msg, v)
+	logj(SeverityWarning, &std, 2, msg, v)
 }
 
 // Error logs events likely to cause problems.
 // Arguments are handled in the manner of fmt.Print.
 func Error(v ...interface{}) {
-	std.Error(v...)
+	log(SeverityError, &std, 2, v...)
 }
 
 // Errorln logs events likely to cause problems.
 // Arguments are handled in the manner of fmt.Println.
 func Errorln(v ...interface{}) {
-	std.Errorln(v...)
+	logln(SeverityError, &std, 2, v...)
 }
 
 // Errorf logs events likely to cause problems.
 // Arguments are handled in the manner of fmt.Printf.
 func Errorf(format string, v ...interface{}) {
-	std.Errorf(format, v...)
+	logf(SeverityError, &std, 2, format, v...)
 }
 
 // Errorj logs events likely to cause problems.
 // Argument v becomes the jsonPayload field of the log entry.
 func Errorj(msg string, v interface{}) {
-	std.Errorj(msg, v)
+	logj(SeverityError, &std, 2, msg, v)
 }
 
 // Critical logs events that cause more severe problems or outages.
 // Arguments are handled in the manner of fmt.Print.
 func Critical(v ...interface{}) {
-	std.Critical(v...)
+	log(SeverityCritical, &std, 2, v...)
 }
 
 // Criticalln logs events that cause more severe problems or outages.
 // Arguments are handled in the manner of fmt.Println.
 func Criticalln(v ...interface{}) {
-	std.Criticalln(v...)
+	logln(SeverityCritical, &std, 2, v...)
 }
 
 // Criticalf logs events that cause more severe problems or outages.
 // Arguments are handled in the manner of fmt.Printf.
 func Criticalf(format string, v ...interface{}) {
-	std.Criticalf(format, v...)
+	logf(SeverityCritical, &std, 2, format, v...)
 }
 
 // Criticalj logs events that cause more severe problems or outages.
 // Argument v becomes the jsonPayload field of the log entry.
 func Criticalj(msg string, v interface{}) {
-	std.Criticalj(msg, v)
+	logj(SeverityCritical, &std, 2, msg, v)
 }
 
 // Print logs routine information, such as ongoing status or performance, same as Info().
 // Arguments are handled in the manner of fmt.Print.
 func Print(v ...interface{}) {
-	std.Print(v...)
+	log(SeverityInfo, &std, 2, v...)
 }
 
 // Println logs routine information, such as ongoing status or performance, same as Infoln().
 // Arguments are handled in the manner of fmt.Println.
 func Println(v ...interface{}) {
-	std.Println(v...)
+	logln(SeverityInfo, &std, 2, v...)
 }
 
 // Printf logs routine information, such as ongoing status or performance, same as Infof().
 // Arguments are handled in the manner of fmt.Printf.
 func Printf(format string, v ...interface{}) {
-	std.Printf(format, v...)
+	logf(SeverityInfo, &std, 2, format, v...)
 }
 
 // Printj logs routine information, such as ongoing status or performance, same as Infoj().
 // Argument v becomes the jsonPayload field of the log entry.
 func Printj(msg string, v interface{}) {
-	std.Printj(msg, v)
+	logj(SeverityInfo, &std, 2, msg, v)
 }
 
-// Fatal is equivalent to a call to Critical() followed by a call to os.Exit(1).
+// Fatal is equivalent to a call to Critical() followed by a call to Exit().
 func Fatal(v ...interface{}) {
-	std.Fatal(v...)
+	log(SeverityCritical, &std, 2, v...)
+	std.Exit()
 }
 
-// Fatalln is equivalent to a call to Criticalln() followed by a call to os.Exit(1).
+// Fatalln is equivalent to a call to Criticalln() followed by a call to Exit().
 func Fatalln(v ...interface{}) {
-	std.Fatalln(v...)
+	logln(SeverityCritical, &std, 2, v...)
+	std.Exit()
 }
 
-// Fatalf is equivalent to a call to Criticalf() followed by a call to os.Exit(1).
+// Fatalf is equivalent to a call to Criticalf() followed by a call to Exit().
 func Fatalf(format string, v ...interface{}) {
-	std.Fatalf(format, v...)
+	logf(SeverityCritical, &std, 2, format, v...)
+	std.Exit()
 }
 
-// Fatalj is equivalent to a call to Criticalj() followed by a call to os.Exit(1).
+// Fatalj is equivalent to a call to Criticalj() followed by a call to Exit().
 func Fatalj(msg string, v interface{}) {
-	std.Fatalj(msg, v)
+	logj(SeverityCritical, &std, 2, msg, v)
+	std.Exit()
 }
 
 // Panic is equivalent to a call to Critical() followed by a call to panic().
 func Panic(v ...interface{}) {
-	std.Panic(v...)
+	msg := fmt.Sprint(v...)
+	logs(SeverityCritical, &std, 2, msg)
+	_ = std.Flush()
+	panic(msg)
 }
 
 // Panicln is equivalent to a call to Criticalln() followed by a call to panic().
 func Panicln(v ...interface{}) {
-	std.Panicln(v...)
+	msg := fmt.Sprintln(v...)
+	logs(SeverityCritical, &std, 2, msg)
+	_ = std.Flush()
+	panic(msg)
 }
 
 // Panicf is equivalent to a call to Criticalf() followed by a call to panic().
 func Panicf(format string, v ...interface{}) {
-	std.Panicf(format, v...)
+	msg := fmt.Sprintf(format, v...)
+	logs(SeverityCritical, &std, 2, msg)
+	_ = std.Flush()
+	panic(msg)
 }
 
 // Panicj is equivalent to a call to Criticalj() followed by a call to panic().
 func Panicj(msg string, v interface{}) {
-	std.Panicj(msg, v)
+	logj(SeverityCritical, &std, 2, msg, v)
+	_ = std.Flush()
+	panic(v)
 }
 
 // Alert logs when a person must take an action immediately.
 // Arguments are handled in the manner of fmt.Print.
 func Alert(v ...interface{}) {
-	std.Alert(v...)
+	log(SeverityAlert, &std, 2, v...)
 }
 
 // Alertln logs when a person must take an action immediately.
 // Arguments are handled in the manner of fmt.Println.
 func Alertln(v ...interface{}) {
-	std.Alertln(v...)
+	logln(SeverityAlert, &std, 2, v...)
 }
 
 // Alertf logs when a person must take an action immediately.
 // Arguments are handled in the manner of fmt.Printf.
 func Alertf(format string, v ...interface{}) {
-	std.Alertf(format, v...)
+	logf(SeverityAlert, &std, 2, format, v...)
 }
 
 // Alertj logs when a person must take an action immediately.
 // Argument v becomes the jsonPayload field of the log entry.
 func Alertj(msg string, v interface{}) {
-	std.Alertj(msg, v)
+	logj(SeverityAlert, &std, 2, msg, v)
 }
 
 // Emergency logs when one or more systems are unusable.
 // Arguments are handled in the manner of fmt.Print.
 func Emergency(v ...interface{}) {
-	std.Emergency(v...)
+	log(SeverityEmergency, &std, 2, v...)
 }
 
 // Emergencyln logs when one or more systems are unusable.
 // Arguments are handled in the manner of fmt.Println.
 func Emergencyln(v ...interface{}) {
-	std.Emergencyln(v...)
+	logln(SeverityEmergency, &std, 2, v...)
 }
 
 // Emergencyf logs when one or more systems are unusable.
 // Arguments are handled in the manner of fmt.Printf.
 func Emergencyf(format string, v ...interface{}) {
-	std.Emergencyf(format, v...)
+	logf(SeverityEmergency, &std, 2, format, v...)
 }
 
 // Emergencyj logs when one or more systems are unusable.
 // Argument v becomes the jsonPayload field of the log entry.
 func Emergencyj(msg string, v interface{}) {
-	std.Emergencyj(msg, v)
+	logj(SeverityEmergency, &std, 2, msg, v)
+}
+
+// Log logs a message of severity s, which is useful when the severity is computed.
+// Arguments are handled in the manner of fmt.Print.
+func Log(s Severity, v ...interface{}) {
+	log(s, &std, 2, v...)
+}
+
+// Logln logs a message of severity s, which is useful when the severity is computed.
+// Arguments are handled in the manner of fmt.Println.
+func Logln(s Severity, v ...interface{}) {
+	logln(s, &std, 2, v...)
+}
+
+// Logf logs a message of severity s, which is useful when the severity is computed.
+// Arguments are handled in the manner of fmt.Printf.
+func Logf(s Severity, format string, v ...interface{}) {
+	logf(s, &std, 2, format, v...)
+}
+
+// Logj logs a message of severity s, which is useful when the severity is computed.
+// Argument v becomes the jsonPayload field of the log entry.
+func Logj(s Severity, msg string, v interface{}) {
+	logj(s, &std, 2, msg, v)
 }
 
 // Debug logs detailed information that could mainly be used to catch unforeseen problems.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Debug(v ...interface{}) {
-	log(debugsev, l, v...)
+	log(SeverityDebug, l, 2, v...)
 }
 
 // Debugln logs detailed information that could mainly be used to catch unforeseen problems.
 // Arguments are handled in the manner of fmt.Println.
 func (l *Logger) Debugln(v ...interface{}) {
-	logln(debugsev, l, v...)
+	logln(SeverityDebug, l, 2, v...)
 }
 
 // Debugf logs detailed information that could mainly be used to catch unforeseen problems.
 // Arguments are handled in the manner of fmt.Printf.
 func (l *Logger) Debugf(format string, v ...interface{}) {
-	logf(debugsev, l, format, v...)
+	logf(SeverityDebug, l, 2, format, v...)
 }
 
 // Debugj logs detailed information that could mainly be used to catch unforeseen problems.
 // Argument v becomes jsonPayload field in the log entry.
 func (l *Logger) Debugj(msg string, v interface{}) {
-	logj(debugsev, l, msg, v)
+	logj(SeverityDebug, l, 2, msg, v)
 }
 
 // Info logs routine information, such as ongoing status or performance.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Info(v ...interface{}) {
-	log(infosev, l, v...)
+	log(SeverityInfo, l, 2, v...)
 }
 
 // Infoln logs routine information, such as ongoing status or performance.
 // Arguments are handled in the manner of fmt.Println.
 func (l *Logger) Infoln(v ...interface{}) {
-	logln(infosev, l, v...)
+	logln(SeverityInfo, l, 2, v...)
 }
 
 // Infof logs routine information, such as ongoing status or performance.
 // Arguments are handled in the manner of fmt.Printf.
 func (l *Logger) Infof(format string, v ...interface{}) {
-	logf(infosev, l, format, v...)
+	logf(SeverityInfo, l, 2, format, v...)
 }
 
 // Infoj logs routine information, such as ongoing status or performance.
 // Argument v becomes the jsonPayload field of the log entry.
 func (l *Logger) Infoj(msg string, v interface{}) {
-	logj(infosev, l, msg, v)
+	logj(SeverityInfo, l, 2, msg, v)
 }
 
 // Notice logs normal but significant events, such as start up, shut down, or configuration.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Notice(v ...interface{}) {
-	log(noticesev, l, v...)
+	log(SeverityNotice, l, 2, v...)
 }
 
 // Noticeln logs normal but significant events, such as start up, shut down, or configuration.
 // Arguments are handled in the manner of fmt.Println.
 func (l *Logger) Noticeln(v ...interface{}) {
-	logln(noticesev, l, v...)
+	logln(SeverityNotice, l, 2, v...)
 }
 
 // Noticef logs normal but significant events, such as start up, shut down, or configuration.
 // Arguments are handled in the manner of fmt.Printf.
 func (l *Logger) Noticef(format string, v ...interface{}) {
-	logf(noticesev, l, format, v...)
+	logf(SeverityNotice, l, 2, format, v...)
 }
 
 // Noticej logs normal but significant events, such as start up, shut down, or configuration.
 // Argument v becomes the jsonPayload field of the log entry.
 func (l *Logger) Noticej(msg string, v interface{}) {
-	logj(noticesev, l, msg, v)
+	logj(SeverityNotice, l, 2, msg, v)
 }
 
 // Warning logs events that might cause problems.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Warning(v ...interface{}) {
-	log(warningsev, l, v...)
+	log(SeverityWarning, l, 2, v...)
 }
 
 // Warningln logs events that might cause problems.
 // Arguments are handled in the manner of fmt.Println.
 func (l *Logger) Warningln(v ...interface{}) {
-	logln(warningsev, l, v...)
+	logln(SeverityWarning, l, 2, v...)
 }
 
 // Warningf logs events that might cause problems.
 // Arguments are handled in the manner of fmt.Printf.
 func (l *Logger) Warningf(format string, v ...interface{}) {
-	logf(warningsev, l, format, v...)
+	logf(SeverityWarning, l, 2, format, v...)
 }
 
 // Warningj logs events that might cause problems.
 // Argument v becomes the jsonPayload field of the log entry.
 func (l *Logger) Warningj(msg string, v interface{}) {
-	logj(warningsev, l, msg, v)
+	logj(SeverityWarning, l, 2, msg, v)
 }
 
 // Error logs events likely to cause problems.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Error(v ...interface{}) {
-	log(errorsev, l, v...)
+	log(SeverityError, l, 2, v...)
 }
 
 // Errorln logs events likely to cause problems.
 // Arguments are handled in the manner of fmt.Println.
 func (l *Logger) Errorln(v ...interface{}) {
-	logln(errorsev, l, v...)
+	logln(SeverityError, l, 2, v...)
 }
 
 // Errorf logs events likely to cause problems.
 // Arguments are handled in the manner of fmt.Printf.
 func (l *Logger) Errorf(format string, v ...interface{}) {
-	logf(errorsev, l, format, v...)
+	logf(SeverityError, l, 2, format, v...)
 }
 
 // Errorj logs events likely to cause problems.
 // Argument v becomes the jsonPayload field of the log entry.
 func (l *Logger) Errorj(msg string, v interface{}) {
-	logj(errorsev, l, msg, v)
+	logj(SeverityError, l, 2, msg, v)
 }
 
 // Critical logs events that cause more severe problems or outages.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Critical(v ...interface{}) {
-	log(criticalsev, l, v...)
+	log(SeverityCritical, l, 2, v...)
 }
 
 // Criticalln logs events that cause more severe problems or outages.
 // Arguments are handled in the manner of fmt.Println.
 func (l *Logger) Criticalln(v ...interface{}) {
-	logln(criticalsev, l, v...)
+	logln(SeverityCritical, l, 2, v...)
 }
 
 // Criticalf logs events that cause more severe problems or outages.
 // Arguments are handled in the manner of fmt.Printf.
 func (l *Logger) Criticalf(format string, v ...interface{}) {
-	logf(criticalsev, l, format, v...)
+	logf(SeverityCritical, l, 2, format, v...)
 }
 
 // Criticalj logs events that cause more severe problems or outages.
 // Argument v becomes the jsonPayload field of the log entry.
 func (l *Logger) Criticalj(msg string, v interface{}) {
-	logj(criticalsev, l, msg, v)
+	logj(SeverityCritical, l, 2, msg, v)
 }
 
 // Alert logs when a person must take an action immediately.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Alert(v ...interface{}) {
-	log(alertsev, l, v...)
+	log(SeverityAlert, l, 2, v...)
 }
 
 // Alertln logs when a person must take an action immediately.
 // Arguments are handled in the manner of fmt.Println.
 func (l *Logger) Alertln(v ...interface{}) {
-	logln(alertsev, l, v...)
+	logln(SeverityAlert, l, 2, v...)
 }
 
 // Alertf logs when a person must take an action immediately.
 // Arguments are handled in the manner of fmt.Printf.
 func (l *Logger) Alertf(format string, v ...interface{}) {
-	logf(alertsev, l, format, v...)
+	logf(SeverityAlert, l, 2, format, v...)
 }
 
 // Alertj logs when a person must take an action immediately.
 // Argument v becomes the jsonPayload field of the log entry.
 func (l *Logger) Alertj(msg string, v interface{}) {
-	logj(alertsev, l, msg, v)
+	logj(SeverityAlert, l, 2, msg, v)
 }
 
 // Emergency logs when one or more systems are unusable.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Emergency(v ...interface{}) {
-	log(emergencysev, l, v...)
+	log(SeverityEmergency, l, 2, v...)
 }
 
 // Emergencyln logs when one or more systems are unusable.
 // Arguments are handled in the manner of fmt.Println.
 func (l *Logger) Emergencyln(v ...interface{}) {
-	logln(emergencysev, l, v...)
+	logln(SeverityEmergency, l, 2, v...)
 }
 
 // Emergencyf logs when one or more systems are unusable.
 // Arguments are handled in the manner of fmt.Printf.
 func (l *Logger) Emergencyf(format string, v ...interface{}) {
-	logf(emergencysev, l, format, v...)
+	logf(SeverityEmergency, l, 2, format, v...)
 }
 
 // Emergencyj logs when one or more systems are unusable.
 // Argument v becomes the jsonPayload field of the log entry.
 func (l *Logger) Emergencyj(msg string, v interface{}) {
-	logj(emergencysev, l, msg, v)
+	logj(SeverityEmergency, l, 2, msg, v)
+}
+
+// Log logs a message of severity s, which is useful when the severity is computed.
+// Arguments are handled in the manner of fmt.Print.
+func (l *Logger) Log(s Severity, v ...interface{}) {
+	log(s, l, 2, v...)
+}
+
+// Logln logs a message of severity s, which is useful when the severity is computed.
+// Arguments are handled in the manner of fmt.Println.
+func (l *Logger) Logln(s Severity, v ...interface{}) {
+	logln(s, l, 2, v...)
+}
+
+// Logf logs a message of severity s, which is useful when the severity is computed.
+// Arguments are handled in the manner of fmt.Printf.
+func (l *Logger) Logf(s Severity, format string, v ...interface{}) {
+	logf(s, l, 2, format, v...)
+}
+
+// Logj logs a message of severity s, which is useful when the severity is computed.
+// Argument v becomes the jsonPayload field of the log entry.
+func (l *Logger) Logj(s Severity, msg string, v interface{}) {
+	logj(s, l, 2, msg, v)
 }
 
 // Print logs routine information, such as ongoing status or performance, same as l.Info().
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Print(v ...interface{}) {
-	log(infosev, l, v...)
+	log(SeverityInfo, l, 2, v...)
 }
 
 // Println logs routine information, such as ongoing status or performance, same as l.Infoln().
 // Arguments are handled in the manner of fmt.Println.
 func (l *Logger) Println(v ...interface{}) {
-	logln(infosev, l, v...)
+	logln(SeverityInfo, l, 2, v...)
 }
 
 // Printf logs routine information, such as ongoing status or performance, same as l.Infof().
 // Arguments are handled in the manner of fmt.Printf.
 func (l *Logger) Printf(format string, v ...interface{}) {
-	logf(infosev, l, format, v...)
+	logf(SeverityInfo, l, 2, format, v...)
 }
 
 // Printj logs routine information, such as ongoing status or performance, same as l.Infoj().
 // Argument v becomes the jsonPayload field of the log entry.
 func (l *Logger) Printj(msg string, v interface{}) {
-	logj(infosev, l, msg, v)
+	logj(SeverityInfo, l, 2, msg, v)
 }
 
-// Fatal is equivalent to a call to l.Critical() followed by a call to os.Exit(1).
+// Fatal is equivalent to a call to l.Critical() followed by a call to l.Exit().
 func (l *Logger) Fatal(v ...interface{}) {
-	log(criticalsev, l, v...)
-	os.Exit(1)
+	log(SeverityCritical, l, 2, v...)
+	l.Exit()
 }
 
-// Fatalln is equivalent to a call to l.Criticalln() followed by a call to os.Exit(1).
+// Fatalln is equivalent to a call to l.Criticalln() followed by a call to l.Exit().
 func (l *Logger) Fatalln(v ...interface{}) {
-	logln(criticalsev, l, v...)
-	os.Exit(1)
+	logln(SeverityCritical, l, 2, v...)
+	l.Exit()
 }
 
-// Fatalf is equivalent to a call to l.Criticalf() followed by a call to os.Exit(1).
+// Fatalf is equivalent to a call to l.Criticalf() followed by a call to l.Exit().
 func (l *Logger) Fatalf(format string, v ...interface{}) {
-	logf(criticalsev, l, format, v...)
-	os.Exit(1)
+	logf(SeverityCritical, l, 2, format, v...)
+	l.Exit()
 }
 
-// Fatalj is equivalent to a call to l.Criticalj() followed by a call to os.Exit(1).
+// Fatalj is equivalent to a call to l.Criticalj() followed by a call to l.Exit().
 func (l *Logger) Fatalj(msg string, v interface{}) {
-	logj(criticalsev, l, msg, v)
-	os.Exit(1)
+	logj(SeverityCritical, l, 2, msg, v)
+	l.Exit()
 }
 
 // Panic is equivalent to a call to l.Critical() followed by a call to panic().
 func (l *Logger) Panic(v ...interface{}) {
-	panic(log(criticalsev, l, v...))
+	msg := fmt.Sprint(v...)
+	logs(SeverityCritical, l, 2, msg)
+	_ = l.Flush()
+	panic(msg)
 }
 
 // Panicln is equivalent to a call to l.Criticalln() followed by a call to panic().
 func (l *Logger) Panicln(v ...interface{}) {
-	panic(logln(criticalsev, l, v...))
+	msg := fmt.Sprintln(v...)
+	logs(SeverityCritical, l, 2, msg)
+	_ = l.Flush()
+	panic(msg)
 }
 
 // Panicf is equivalent to a call to l.Criticalf() followed by a call to panic().
 func (l *Logger) Panicf(format string, v ...interface{}) {
-	panic(logf(criticalsev, l, format, v...))
+	msg := fmt.Sprintf(format, v...)
+	logs(SeverityCritical, l, 2, msg)
+	_ = l.Flush()
+	panic(msg)
 }
 
 // Panicj is equivalent to a call to l.Criticalj() followed by a call to panic().
 func (l *Logger) Panicj(msg string, v interface{}) {
-	logj(criticalsev, l, msg, v)
+	logj(SeverityCritical, l, 2, msg, v)
+	_ = l.Flush()
 	panic(v)
 }
 
 type Logger struct {
-	out   io.Writer
-	err   io.Writer
-	mu    sync.Mutex
+	out io.Writer
+	err io.Writer
+	mu  sync.Mutex
+	// sink is the sinkValue set by SetSink, or nil, in the root Logger like out and err.
+	sink  atomic.Value
 	trace json.RawMessage
+	// spanID and sampled are set only together with the trace.
+	spanID  json.RawMessage
+	sampled json.RawMessage
+	level   setting // minimum Severity
+	flags   setting // the flags like Lshortfile
+	// reporting is non-zero if the entries of severity ERROR and above go to Error Reporting.
+	reporting setting
+	// panicPolicy is the PanicPolicy of RecoverMiddleware and Go.
+	panicPolicy setting
+	format      setting // the Format
+	// exit is the *exitConfig of Exit, or nil for the one of base.
+	exit atomic.Value
+	// prefix is the prefix string, or nil for the one of base.
+	prefix atomic.Value
+	// encoder is the encoderValue set by SetEncoder, or nil for the one of base.
+	encoder atomic.Value
+
+	// callerSkip is the number of additional stack frames to skip, when finding the source location.
+	callerSkip int
+
+	// parent is the Logger owning out, err and mu, when this is a child Logger created by With.
+	parent *Logger
+	// base is the Logger, which this child Logger was created from. The child inherits
+	// the settings of base, such as the level, until they are set on the child itself.
+	base *Logger
+	// fields are the pre-encoded JSON object members added to every entry, without the braces.
+	fields []byte
+	// labels and httpRequest are added to every entry, see WithLabels and WithHTTPRequest.
+	labels      map[string]string
+	httpRequest *HTTPRequest
+}
+
+// SetLevel sets the minimum severity of the messages logged through l. Messages of
+// a lower severity are discarded before their arguments are formatted or marshaled.
+// It is safe to call SetLevel while other goroutines are logging.
+func (l *Logger) SetLevel(s Severity) {
+	l.level.store(int32(s))
+}
+
+// Level returns the minimum severity of the messages logged through l.
+func (l *Logger) Level() Severity {
+	return Severity(l.load(func(l *Logger) *setting { return &l.level }))
+}
+
+// Enabled returns true if the messages of severity s are logged through l.
+// It is useful to avoid computing expensive arguments of a message that is
+// discarded anyway.
+func (l *Logger) Enabled(s Severity) bool {
+	return s >= l.Level()
 }
 
 // ForRequest creates a new Logger. All the messages logged through it will trace
-// back to the HTTP request, based on its header "X-Cloud-Trace-Context" combined
-// with the package var ProjectID.
+// back to the HTTP request, based on its header "traceparent" or "X-Cloud-Trace-Context"
+// combined with the package var ProjectID. Besides the trace, the entries carry the span ID
+// and the sampling decision of the request, so that Cloud Trace nests them under
+// the right span. The requests that are not sampled are still traced.
+//
+// The W3C header "traceparent" takes precedence, as it is the one propagated by
+// OpenTelemetry. The header "X-Cloud-Trace-Context" is used when "traceparent" is
+// missing, repeated, or invalid.
+//
+// The new Logger is a child of the package-level logger, see (*Logger).ForRequest.
 //
 // Setting package var ProjectID to empty disables such tracing altogether.
 func ForRequest(request *http.Request) *Logger {
-	l := &Logger{}
+	return std.ForRequest(request)
+}
+
+// ForRequest creates a child Logger of l, in the manner of With, whose messages
+// trace back to the HTTP request. See the package-level ForRequest for the details.
+func (l *Logger) ForRequest(request *http.Request) *Logger {
+	c := l.child(nil)
+	c.trace, c.spanID, c.sampled = nil, nil, nil
 
 	if ProjectID != "" {
-		h := request.Header.Get("X-Cloud-Trace-Context")
-		// "X-Cloud-Trace-Context: TRACE_ID/SPAN_ID;o=TRACE_TRUE" meaning:
-		// TRACE_ID is a 32-character hexadecimal value representing a 128-bit number. [Future-proofing to 256-char.]
-		// SPAN_ID is the decimal representation of [unsigned integer of unspecified bitlength].
-		// TRACE_TRUE must be `1` to trace this request. Specify `0` to not trace the request.
-		if i := strings.IndexByte(h, '/'); i > 0 && i <= 256 {
-			if strings.Contains(h[i:], ";o=0") {
-				return l
-			}
+		if h := request.Header["Traceparent"]; len(h) == 1 {
+			if tc, ok := parseTraceparent(h[0]); ok {
+				c.setTrace(tc)
 
-			t := h[:i]
-			if strings.TrimLeft(t, "0123456789abcdefABCDEFxX") != "" {
-				return l
+				return c
 			}
+		}
 
-			if strings.Count(t, "0") != len(t) {
-				b, err := marshalJSON(fmt.Sprintf("projects/%s/traces/%s", ProjectID, t))
-				if err != nil {
-					return l
-				}
-				l.trace = b
-			}
+		if tc, ok := parseCloudTraceContext(request.Header.Get("X-Cloud-Trace-Context")); ok {
+			c.setTrace(tc)
 		}
 	}
 
-	return l
+	return c
+}
+
+// setTrace sets the trace, span ID and sampling fields of l. It expects that
+// the package var ProjectID is not empty.
+func (l *Logger) setTrace(tc traceContext) {
+	b, err := marshalJSON(fmt.Sprintf("projects/%s/traces/%s", ProjectID, tc.traceID))
+	if err != nil {
+		return
+	}
+	l.trace = b
+
+	if tc.spanID != "" {
+		l.spanID = []byte(`"` + tc.spanID + `"`)
+	}
+
+	if tc.sampled != "" {
+		l.sampled = []byte(tc.sampled)
+	}
 }
 
 // New is for interface-level compatibility with standard library's
 // "log" package. It creates a new Logger, which streams all its messages to w.
-// Remaining arguments are ignored.
+// The prefix and the flag are handled as described for SetPrefix and SetFlags.
 //
 // The ForRequest() constructor is more useful.
-func New(w io.Writer, dummy2 string, dummy3 int) *Logger {
-	return &Logger{
+func New(w io.Writer, prefix string, flag int) *Logger {
+	l := &Logger{
 		out: w,
 		err: w,
 	}
-}
+	l.flags.store(int32(flag))
+	if prefix != "" {
+		l.prefix.Store(prefix)
+	}
 
-func (l *Logger) writer(s severity) io.Writer {
-	if s.IsErrorish() {
-		if l.err != nil {
-			return l.err
-		}
+	return l
+}
 
-		return os.Stderr
+// With returns a child Logger, which adds the key-value pairs to the jsonPayload of
+// every log entry. The arguments alternate between string keys and their values,
+// for example l.With("user", userID, "tenant", tenantID). The values are encoded
+// once, by With itself, in the manner of Logw.
+//
+// A key that is not a string, or a lone final value, is logged under the key "!BADKEY".
+// A Field in place of a key stands for a whole key-value pair.
+//
+// The child Logger writes to the same writers as l, while holding the same lock,
+// and it inherits the trace of l. It also inherits the settings of l, such as the minimum
+// severity or the flags, and follows their later changes, until they are set on the child.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	return l.child(appendKeyValues(nil, kv))
+}
+
+// WithFields returns a child Logger, which adds the fields of v to the jsonPayload of
+// every log entry. Argument v is marshaled to JSON once, by WithFields itself, and
+// it is handled in the manner of Printj, so it should be a struct or a map.
+func (l *Logger) WithFields(v interface{}) *Logger {
+	buf, err := marshalJSON(v)
+	if err != nil {
+		buf = []byte(`{"logLibMsg":"cannot marshal the argument as jsonPayload"}`)
 	}
 
-	if l.out != nil {
-		return l.out
+	if len(buf) > 0 && buf[0] == '{' {
+		buf = bytes.TrimSpace(buf[1 : len(buf)-1])
+	} else {
+		buf = append([]byte(`"value":`), buf...)
 	}
 
-	return os.Stdout
+	return l.child(buf)
 }
 
-type severity int32
+// WithLabels returns a child Logger, which adds the labels to every log entry, in the field
+// "logging.googleapis.com/labels". Cloud Logging indexes the labels, unlike the fields of
+// the jsonPayload. The labels of l are kept, unless replaced by the labels of the same keys.
+func (l *Logger) WithLabels(labels map[string]string) *Logger {
+	c := l.child(nil)
+	c.labels = make(map[string]string, len(l.labels)+len(labels))
+	for k, v := range l.labels {
+		c.labels[k] = v
+	}
+	for k, v := range labels {
+		c.labels[k] = v
+	}
 
-const (
-	debugsev severity = iota * 100
-	infosev
-	noticesev
-	warningsev
-	errorsev
-	criticalsev
-	alertsev
-	emergencysev
-)
+	return c
+}
+
+func (l *Logger) child(fields []byte) *Logger {
+	c := &Logger{
+		parent:      l.root(),
+		base:        l,
+		trace:       l.trace,
+		spanID:      l.spanID,
+		sampled:     l.sampled,
+		callerSkip:  l.callerSkip,
+		labels:      l.labels,
+		httpRequest: l.httpRequest,
+	}
 
-func (s severity) MarshalJSON() ([]byte, error) {
-	switch s {
+	switch {
+	case len(l.fields) == 0:
+		c.fields = fields
+	case len(fields) == 0:
+		c.fields = l.fields
 	default:
-		return []byte(`"UNKNOWN"`), fmt.Errorf("unknown severity: %d", s)
-	case debugsev:
-		return []byte(`"DEBUG"`), nil
-	case infosev:
-		return []byte(`"INFO"`), nil
-	case noticesev:
-		return []byte(`"NOTICE"`), nil
-	case warningsev:
-		return []byte(`"WARNING"`), nil
-	case errorsev:
-		return []byte(`"ERROR"`), nil
-	case criticalsev:
-		return []byte(`"CRITICAL"`), nil
-	case alertsev:
-		return []byte(`"ALERT"`), nil
-	case emergencysev:
-		return []byte(`"EMERGENCY"`), nil
+		c.fields = make([]byte, 0, len(l.fields)+1+len(fields))
+		c.fields = append(c.fields, l.fields...)
+		c.fields = append(c.fields, ',')
+		c.fields = append(c.fields, fields...)
 	}
+
+	return c
 }
 
-// IsErrorish returns true for severity ERROR and above it.
-func (s severity) IsErrorish() bool {
-	return s >= errorsev
+// setting is an int32 setting of a Logger, such as the level, accessed atomically.
+type setting struct {
+	v int32
+	// set is non-zero, if v was stored, so that a child Logger does not inherit it.
+	set int32
 }
 
-func log(s severity, l *Logger, v ...interface{}) string {
-	return logs(s, l, fmt.Sprint(v...))
+func (s *setting) store(v int32) {
+	atomic.StoreInt32(&s.v, v)
+	atomic.StoreInt32(&s.set, 1)
 }
 
-func logln(s severity, l *Logger, v ...interface{}) string {
-	return logs(s, l, fmt.Sprintln(v...))
+// load returns the setting of l chosen by the function field, which is either set on l,
+// or inherited from the base of l.
+func (l *Logger) load(field func(*Logger) *setting) int32 {
+	for {
+		s := field(l)
+		if l.base == nil || atomic.LoadInt32(&s.set) != 0 {
+			return atomic.LoadInt32(&s.v)
+		}
+		l = l.base
+	}
 }
 
-func logf(s severity, l *Logger, format string, v ...interface{}) string {
-	return logs(s, l, fmt.Sprintf(format, v...))
+// loadValue returns the value of l chosen by the function field, which is either stored
+// in l, or inherited from the base of l, or nil.
+func (l *Logger) loadValue(field func(*Logger) *atomic.Value) interface{} {
+	for ; l != nil; l = l.base {
+		if v := field(l).Load(); v != nil {
+			return v
+		}
+	}
+
+	return nil
 }
 
-type entry struct {
-	Message  string          `json:"message"`
-	Severity severity        `json:"severity,omitempty"`
-	Trace    json.RawMessage `json:"logging.googleapis.com/trace,omitempty"`
+// root returns the Logger owning the writers and the mutex of l.
+func (l *Logger) root() *Logger {
+	if l.parent != nil {
+		return l.parent
+	}
+
+	return l
 }
 
-func logs(s severity, l *Logger, msg string) string {
-	entry := entry{msg, s, l.trace}
+// badKey is logged instead of a key that is missing or is not a string.
+const badKey = "!BADKEY"
 
-	encoder := json.NewEncoder(l.writer(s))
-	encoder.SetEscapeHTML(false)
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	_ = encoder.Encode(entry)
+// appendKeyValues appends to buf the JSON object members encoded from kv, which alternates
+// between keys and values, possibly interleaved with Fields. The members are separated by
+// commas, without the braces.
+func appendKeyValues(buf []byte, kv []interface{}) []byte {
+	for i := 0; i < len(kv); i++ {
+		if f, ok := kv[i].(Field); ok {
+			buf = f.appendMember(buf)
+			continue
+		}
+
+		key, ok := kv[i].(string)
+		if !ok || i == len(kv)-1 {
+			key = badKey
+		} else {
+			i++
+		}
 
-	return msg
+		buf = appendKeyValue(buf, key, kv[i])
+	}
+
+	return buf
+}
+
+func appendKeyValue(buf []byte, key string, v interface{}) []byte {
+	buf = appendComma(buf)
+	buf = appendJSONString(buf, key)
+	buf = append(buf, ':')
+
+	return appendJSONValue(buf, v)
+}
+
+func (l *Logger) writer(s Severity) io.Writer {
+	if s.IsErrorish() {
+		if l.err != nil {
+			return l.err
+		}
+
+		return os.Stderr
+	}
+
+	if l.out != nil {
+		return l.out
+	}
+
+	return os.Stdout
+}
+
+// log, logln, logf and logj check the minimum severity before doing any formatting,
+// so that the discarded messages are cheap.
+//
+// The depth argument of the internal logging functions is the number of stack frames
+// between the function and the user's code, in the manner of runtime.Caller. A function
+// passes depth+1 to the functions it calls. The exported functions and methods call them
+// with the depth 2.
+func log(s Severity, l *Logger, depth int, v ...interface{}) {
+	if !l.Enabled(s) {
+		return
+	}
+	logs(s, l, depth+1, fmt.Sprint(v...))
 }
 
-func logj(s severity, l *Logger, msg string, item interface{}) {
+func logln(s Severity, l *Logger, depth int, v ...interface{}) {
+	if !l.Enabled(s) {
+		return
+	}
+	logs(s, l, depth+1, fmt.Sprintln(v...))
+}
+
+func logf(s Severity, l *Logger, depth int, format string, v ...interface{}) {
+	if !l.Enabled(s) {
+		return
+	}
+	logs(s, l, depth+1, fmt.Sprintf(format, v...))
+}
+
+func logs(s Severity, l *Logger, depth int, msg string) error {
+	if !l.Enabled(s) {
+		return nil
+	}
+	msg, t, prefix := l.header(msg)
+	e := Entry{
+		Severity:       s,
+		Message:        msg,
+		Time:           t,
+		Prefix:         prefix,
+		SourceLocation: l.sourceLocation(depth + 1),
+	}
+
+	return l.write(&e, l.errorReport(s, depth+1), nil)
+}
+
+func logj(s Severity, l *Logger, depth int, msg string, item interface{}) {
+	if !l.Enabled(s) {
+		return
+	}
+
 	// Would be nice to check for duplicated fields, e.g. "message", if a user throws at us a map which they don't
 	// bother to sanitize.
 	//
@@ -703,131 +1019,59 @@ func logj(s severity, l *Logger, msg string, item interface{}) {
 	if err != nil {
 		// Do not include the err: do not risk infinite loop when err itself has a custom marshaler that returns
 		// the same error.
-		logRawJSON(s, l, msg, []byte(`{"logLibMsg":"cannot marshal the argument as jsonPayload"}`))
+		logRawJSON(s, l, depth+1, msg, []byte(`{"logLibMsg":"cannot marshal the argument as jsonPayload"}`))
 
 		return
 	}
 
-	logRawJSON(s, l, msg, buf)
+	logRawJSON(s, l, depth+1, msg, buf)
 }
 
 // marshalJSON is exactly like json.Marshal except it uses option SetEscapeHTML(false)
-// in order to not to mange the output and that it pre-allocates the buffer at 1024 bytes.
+// in order to not to mange the output and that it reuses the encoders and their buffers.
 func marshalJSON(in interface{}) ([]byte, error) {
-	buf := bytes.NewBuffer(make([]byte, 0, 1024))
-	encoder := json.NewEncoder(buf)
-	encoder.SetEscapeHTML(false)
-	err := encoder.Encode(in)
-	if err != nil {
+	e := jsonEncoderPool.Get().(*jsonEncoder)
+	defer func() {
+		if e.buf.Cap() <= maxPooledBuffer {
+			e.buf.Reset()
+			jsonEncoderPool.Put(e)
+		}
+	}()
+
+	if err := e.enc.Encode(in); err != nil {
 		return nil, err
 	}
 
 	// Remove the final new line.
-	res := bytes.TrimRight(buf.Bytes(), "\n")
-	return res, err
+	res := bytes.TrimRight(e.buf.Bytes(), "\n")
+	return append([]byte(nil), res...), nil
 }
 
 // logRawJSON writes the buf to the l logger. The buf should be
 // an encoded JSON and its first byte must be '{'.
 // The s and msg are brutally inserted as "severity" and "message" top-level JSON fields.
-// The buf should not contain "severity", "message", or "logging.googleapis.com/trace"
+// The error report, see SetErrorReporting, and the fields of a child Logger are inserted
+// before the content of buf.
+// The buf should not contain "severity", "message", or "logging.googleapis.com/..."
 // top-level JSON fields.
 // No attempt is made to check whether the resulting string does not have these fields
 // duplicated and whether it is a valid JSON. Spoiler alert: GCP Logging API seems to be
 // quite gracefully handling malformed JSON entries with such duplicate fields.
-func logRawJSON(s severity, l *Logger, msg string, buf []byte) {
-	var msgj, sevj []byte
-	var err error
-
-	if msg != "" {
-		msgj, err = marshalJSON(msg)
-		if err != nil {
-			return
-		}
-	}
-
-	w := l.writer(s)
-	jsonStruct := len(buf) > 0 && buf[0] == '{'
-
-	if jsonStruct {
-		buf = buf[1:]
-	}
-
-	// Critical Section
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	if _, err := w.Write([]byte("{")); err != nil {
-		return
-	}
-
-	comma := []byte{}
-
-	if msg != "" {
-		if _, err := w.Write([]byte("\"message\":")); err != nil {
-			return
-		}
-		if _, err := w.Write(msgj); err != nil {
-			return
-		}
-
-		comma = []byte(",")
-	}
-
-	sevj, err = s.MarshalJSON()
-	if err == nil {
-		if _, err := w.Write(comma); err != nil {
-			return
-		}
-		if _, err := w.Write([]byte("\"severity\":")); err != nil {
-			return
-		}
-		if _, err := w.Write(sevj); err != nil {
-			return
-		}
-
-		comma = []byte(",")
-	}
-
-	if len(l.trace) != 0 {
-		if _, err := w.Write(comma); err != nil {
-			return
-		}
-		if _, err := w.Write([]byte("\"logging.googleapis.com/trace\":")); err != nil {
-			return
-		}
-		if _, err := w.Write(l.trace); err != nil {
-			return
-		}
-
-		comma = []byte(",")
-	}
-
-	if !jsonStruct {
-		if _, err := w.Write(comma); err != nil {
-			return
-		}
-		if _, err := w.Write([]byte("\"value\":")); err != nil {
-			return
-		}
-		if _, err := w.Write(buf); err != nil {
-			return
-		}
-		_, _ = w.Write([]byte("}\n"))
-
-		return
-	}
-
-	if len(buf) > 0 && buf[0] != '}' {
-		if _, err := w.Write(comma); err != nil {
-			return
-		}
-	}
-	if _, err := w.Write(buf); err != nil {
-		return
-	}
-
-	if buf[len(buf)-1] != '\n' {
-		_, _ = w.Write([]byte("\n"))
+func logRawJSON(s Severity, l *Logger, depth int, msg string, buf []byte) error {
+	msg, t, prefix := l.header(msg)
+	return writeRawJSON(s, l, msg, t, prefix, l.sourceLocation(depth+1), l.errorReport(s, depth+1), buf)
+}
+
+// writeRawJSON is logRawJSON with the message, the time, the prefix field, the source
+// location and the error report already worked out. It returns the error of the write.
+func writeRawJSON(s Severity, l *Logger, msg string, t time.Time, prefix string, loc *SourceLocation, report, buf []byte) error {
+	e := Entry{
+		Severity:         s,
+		Message:          msg,
+		Time:             t,
+		SourceLocation:   loc,
+		Prefix:           prefix,
+		omitEmptyMessage: true,
 	}
+	return l.write(&e, report, buf)
 }
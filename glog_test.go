@@ -1,12 +1,15 @@
 package log
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -32,9 +35,48 @@ func TestLogger_Panic(t *testing.T) {
 	l.Panic("a")
 }
 
+func TestLogger_PanicFlushes(t *testing.T) {
+	tests := []struct {
+		name  string
+		panic func(l *Logger)
+	}{
+		{"Panic", func(l *Logger) { l.Panic("bye") }},
+		{"Panicf", func(l *Logger) { l.Panicf("%s", "bye") }},
+		{"Panicln", func(l *Logger) { l.Panicln("bye") }},
+		{"Panicj", func(l *Logger) { l.Panicj("bye", nil) }},
+		{"package Panicj", func(l *Logger) {
+			std.SetOutput(l.Writer())
+			defer std.SetOutput(nil)
+			Panicj("bye", nil)
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			buf := &bytes.Buffer{}
+			bw := bufio.NewWriter(buf)
+			l := New(bw, "", 0)
+
+			// Act
+			func() {
+				defer func() { _ = recover() }()
+				tt.panic(l)
+			}()
+
+			// Assert
+			if !strings.Contains(buf.String(), `"severity":"CRITICAL"`) {
+				t.Errorf("unexpected output %q, the entry was not flushed before panicking", buf.String())
+			}
+		})
+	}
+}
+
 func TestLogger_Print(t *testing.T) {
 	type fields struct {
-		trace []byte
+		trace   []byte
+		spanID  []byte
+		sampled []byte
 	}
 
 	tests := []struct {
@@ -61,6 +103,16 @@ func TestLogger_Print(t *testing.T) {
 		name: "ampersand",
 		arg:  "m&m",
 		want: `{"message":"m&m","severity":"INFO"}
+`,
+	}, {
+		name: "tracing with a span",
+		fields: fields{
+			trace:   []byte(`"123"`),
+			spanID:  []byte(`"000000000000004d"`),
+			sampled: []byte(`true`),
+		},
+		arg: "test",
+		want: `{"message":"test","severity":"INFO","logging.googleapis.com/trace":"123","logging.googleapis.com/spanId":"000000000000004d","logging.googleapis.com/trace_sampled":true}
 `,
 	}}
 
@@ -71,9 +123,11 @@ func TestLogger_Print(t *testing.T) {
 			buf := &bytes.Buffer{}
 
 			l := &Logger{
-				out:   buf,
-				err:   nil,
-				trace: tt.fields.trace,
+				out:     buf,
+				err:     nil,
+				trace:   tt.fields.trace,
+				spanID:  tt.fields.spanID,
+				sampled: tt.fields.sampled,
 			}
 
 			// AAA: Act
@@ -94,9 +148,11 @@ func TestLogger_Debugj(t *testing.T) {
 	buf := &bytes.Buffer{}
 
 	type fields struct {
-		out   io.Writer
-		err   io.Writer
-		trace []byte
+		out     io.Writer
+		err     io.Writer
+		trace   []byte
+		spanID  []byte
+		sampled []byte
 	}
 
 	type args struct {
@@ -129,6 +185,20 @@ func TestLogger_Debugj(t *testing.T) {
 			v:   struct{ Text string }{Text: "t"},
 		},
 		want: `{"message":"test","severity":"DEBUG","logging.googleapis.com/trace":"123","Text":"t"}
+`,
+	}, {
+		name: "tracing with a span",
+		fields: fields{
+			out:     buf,
+			trace:   []byte(`"123"`),
+			spanID:  []byte(`"000000000000004d"`),
+			sampled: []byte(`false`),
+		},
+		args: args{
+			msg: "test",
+			v:   struct{ Text string }{Text: "t"},
+		},
+		want: `{"message":"test","severity":"DEBUG","logging.googleapis.com/trace":"123","logging.googleapis.com/spanId":"000000000000004d","logging.googleapis.com/trace_sampled":false,"Text":"t"}
 `,
 	}, {
 		name:   "empty struct",
@@ -189,9 +259,11 @@ func TestLogger_Debugj(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			l := &Logger{
-				out:   tt.fields.out,
-				err:   tt.fields.err,
-				trace: tt.fields.trace,
+				out:     tt.fields.out,
+				err:     tt.fields.err,
+				trace:   tt.fields.trace,
+				spanID:  tt.fields.spanID,
+				sampled: tt.fields.sampled,
 			}
 			l.Debugj(tt.args.msg, tt.args.v)
 			if tt.want != buf.String() {
@@ -570,14 +642,14 @@ func BenchmarkStdlibTen(b *testing.B) {
 		Field10: "test",
 	}
 	for i := 0; i < b.N; i++ {
-		logjStdlib(debugsev, l, "test", msg)
+		logjStdlib(SeverityDebug, l, "test", msg)
 		buf.Reset()
 	}
 }
 
 // logjStdlib is only here to benchmark the stdlib "encoding/json"
 // approach. Hopefully our method is much faster than stdlib.
-func logjStdlib(s severity, l *Logger, msg string, j interface{}) {
+func logjStdlib(s Severity, l *Logger, msg string, j interface{}) {
 	entry := make(map[string]json.RawMessage)
 
 	if buf, err := json.Marshal(j); err != nil {
@@ -631,7 +703,9 @@ func TestForRequest(t *testing.T) {
 			"X-Cloud-Trace-Context": []string{"00000000000000000000000000000001/1;o=1"},
 		}}},
 		want: &Logger{
-			trace: []byte(`"projects/my-project/traces/00000000000000000000000000000001"`),
+			trace:   []byte(`"projects/my-project/traces/00000000000000000000000000000001"`),
+			spanID:  []byte(`"0000000000000001"`),
+			sampled: []byte(`true`),
 		},
 	}, {
 		name:      "tracing header without the o option",
@@ -640,14 +714,92 @@ func TestForRequest(t *testing.T) {
 			"X-Cloud-Trace-Context": []string{"00000000000000000000000000000001/1"},
 		}}},
 		want: &Logger{
-			trace: []byte(`"projects/my-project/traces/00000000000000000000000000000001"`),
+			trace:  []byte(`"projects/my-project/traces/00000000000000000000000000000001"`),
+			spanID: []byte(`"0000000000000001"`),
 		},
 	}, {
-		name:      "o=0 header disables tracing",
+		name:      "o=0 header is traced but not sampled",
 		projectID: "my-project",
 		args: args{req: &http.Request{Header: http.Header{
 			"X-Cloud-Trace-Context": []string{"00000000000000000000000000000001/1;o=0"},
 		}}},
+		want: &Logger{
+			trace:   []byte(`"projects/my-project/traces/00000000000000000000000000000001"`),
+			spanID:  []byte(`"0000000000000001"`),
+			sampled: []byte(`false`),
+		},
+	}, {
+		name:      "large span id",
+		projectID: "my-project",
+		args: args{req: &http.Request{Header: http.Header{
+			"X-Cloud-Trace-Context": []string{"105445aa7843bc8bf206b12000100000/18446744073709551615;o=1"},
+		}}},
+		want: &Logger{
+			trace:   []byte(`"projects/my-project/traces/105445aa7843bc8bf206b12000100000"`),
+			spanID:  []byte(`"ffffffffffffffff"`),
+			sampled: []byte(`true`),
+		},
+	}, {
+		name:      "malformed span id",
+		projectID: "my-project",
+		args: args{req: &http.Request{Header: http.Header{
+			"X-Cloud-Trace-Context": []string{"00000000000000000000000000000001/x1;o=1"},
+		}}},
+		want: &Logger{
+			trace:   []byte(`"projects/my-project/traces/00000000000000000000000000000001"`),
+			sampled: []byte(`true`),
+		},
+	}, {
+		name:      "traceparent",
+		projectID: "my-project",
+		args: args{req: &http.Request{Header: http.Header{
+			"Traceparent": []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		}}},
+		want: &Logger{
+			trace:   []byte(`"projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736"`),
+			spanID:  []byte(`"00f067aa0ba902b7"`),
+			sampled: []byte(`false`),
+		},
+	}, {
+		name:      "traceparent takes precedence",
+		projectID: "my-project",
+		args: args{req: &http.Request{Header: http.Header{
+			"Traceparent":           []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			"X-Cloud-Trace-Context": []string{"00000000000000000000000000000001/1;o=1"},
+		}}},
+		want: &Logger{
+			trace:   []byte(`"projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736"`),
+			spanID:  []byte(`"00f067aa0ba902b7"`),
+			sampled: []byte(`true`),
+		},
+	}, {
+		name:      "invalid traceparent falls back",
+		projectID: "my-project",
+		args: args{req: &http.Request{Header: http.Header{
+			"Traceparent":           []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+			"X-Cloud-Trace-Context": []string{"00000000000000000000000000000001/1;o=1"},
+		}}},
+		want: &Logger{
+			trace:   []byte(`"projects/my-project/traces/00000000000000000000000000000001"`),
+			spanID:  []byte(`"0000000000000001"`),
+			sampled: []byte(`true`),
+		},
+	}, {
+		name:      "repeated traceparent falls back",
+		projectID: "my-project",
+		args: args{req: &http.Request{Header: http.Header{
+			"Traceparent": []string{
+				"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			},
+		}}},
+		want: &Logger{},
+	}, {
+		name:      "all-zero trace id",
+		projectID: "my-project",
+		args: args{req: &http.Request{Header: http.Header{
+			"X-Cloud-Trace-Context": []string{"00000000000000000000000000000000/1;o=1"},
+		}}},
 		want: &Logger{},
 	}, {
 		name:      "bad header no tid",
@@ -668,6 +820,8 @@ func TestForRequest(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			ProjectID = tt.projectID
+			tt.want.parent = &std
+			tt.want.base = &std
 
 			got := ForRequest(tt.args.req)
 
@@ -677,3 +831,175 @@ func TestForRequest(t *testing.T) {
 		})
 	}
 }
+
+// countingStringer counts how many times it has been formatted.
+type countingStringer struct {
+	n *int
+}
+
+func (c countingStringer) String() string {
+	*c.n++
+	return "c"
+}
+
+func TestLogger_SetLevel(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"w","severity":"WARNING"}
+{"message":"e","severity":"ERROR"}
+`
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	formatted := 0
+
+	// Act
+	l.SetLevel(SeverityWarning)
+	l.Debug(countingStringer{&formatted})
+	l.Infof("%v", countingStringer{&formatted})
+	l.Noticej("n", countingStringer{&formatted})
+	l.Warning("w")
+	l.Error("e")
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), wantJSON)
+	}
+	if formatted != 0 {
+		t.Errorf("discarded arguments were formatted %d times", formatted)
+	}
+	if got := l.Level(); got != SeverityWarning {
+		t.Errorf("Level() = %v, want %v", got, SeverityWarning)
+	}
+}
+
+func TestLogger_PanicBelowLevel(t *testing.T) {
+	// Arrange
+	wantPanic := "a"
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	l.SetLevel(SeverityEmergency)
+
+	// Assert
+	defer func() {
+		if gotPanic := recover(); gotPanic != wantPanic {
+			t.Errorf("unexpected panic, got:\n%q\nexpected:\n%q\n", gotPanic, wantPanic)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("unexpected output:\n%q\n", buf.String())
+		}
+	}()
+
+	// Act
+	l.Panic("a")
+}
+
+func TestLogger_SetLevelConcurrently(t *testing.T) {
+	l := New(ioutil.Discard, "", 0)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			l.SetLevel(Severity(i % 9 * 100))
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		l.Info("test")
+	}
+	<-done
+}
+
+func TestLogger_With(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"a","severity":"INFO","user":"u1","n":3}
+{"message":"b","severity":"WARNING","user":"u1","n":3,"job":{"ID":7},"Text":"t"}
+{"message":"c","severity":"ERROR","!BADKEY":1,"!BADKEY":"x"}
+{"message":"d","severity":"DEBUG","m&m":"not brown","x":true}
+`
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+
+	// Act
+	c := l.With("user", "u1", "n", 3)
+	c.Info("a")
+	c.With("job", struct{ ID int }{7}).Warningj("b", struct{ Text string }{"t"})
+	l.With(1, "x").Error("c")
+	l.WithFields(map[string]string{"m&m": "not brown"}).With("x", true).Debug("d")
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", buf.String(), wantJSON)
+	}
+}
+
+func TestLogger_WithInherits(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"a","severity":"WARNING","logging.googleapis.com/trace":"123","k":"v"}
+`
+	buf := &bytes.Buffer{}
+	l := &Logger{out: buf, trace: []byte(`"123"`)}
+	l.SetLevel(SeverityWarning)
+
+	// Act
+	c := l.With("k", "v")
+	c.Notice("discarded")
+	c.Warning("a")
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), wantJSON)
+	}
+	if c.root() != l {
+		t.Errorf("child does not share the parent's writers and lock")
+	}
+}
+
+func TestLogger_WithFollowsSettings(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"c","severity":"NOTICE","prefix":"p","k":"v"}
+{"message":"g","severity":"ERROR","prefix":"p","k":"v","g":1}
+{"message":"c","severity":"ERROR","prefix":"p","k":"v"}
+`
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	c := l.With("k", "v")
+	g := c.With("g", 1)
+
+	// Act
+	l.SetLevel(SeverityNotice)
+	l.SetPrefix("p")
+	c.Info("discarded")
+	c.Notice("c")
+	c.SetLevel(SeverityError)
+	l.SetLevel(SeverityDebug)
+	g.Warning("discarded")
+	g.Error("g")
+	c.Warning("discarded")
+	c.Error("c")
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), wantJSON)
+	}
+	if l.Level() != SeverityDebug || c.Level() != SeverityError || g.Level() != SeverityError {
+		t.Errorf("levels %v, %v and %v, want DEBUG, ERROR and ERROR", l.Level(), c.Level(), g.Level())
+	}
+}
+
+func TestLogger_WithFieldsNotObject(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"a","severity":"INFO","value":[1,2]}
+{"message":"b","severity":"INFO","logLibMsg":"cannot marshal the argument as jsonPayload"}
+`
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+
+	// Act
+	l.WithFields([]int{1, 2}).Info("a")
+	l.WithFields(failingType(0)).Info("b")
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), wantJSON)
+	}
+}
@@ -0,0 +1,199 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPRequest describes an HTTP request, either received or sent, in the manner of the
+// HttpRequest of the Cloud Logging API v2 as described in
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#httprequest.
+// Attach it to log entries with WithHTTPRequest. The zero fields are omitted.
+type HTTPRequest struct {
+	RequestMethod                  string
+	RequestURL                     string
+	RequestSize                    int64
+	Status                         int
+	ResponseSize                   int64
+	UserAgent                      string
+	RemoteIP                       string
+	ServerIP                       string
+	Referer                        string
+	Latency                        time.Duration
+	CacheLookup                    bool
+	CacheHit                       bool
+	CacheValidatedWithOriginServer bool
+	CacheFillBytes                 int64
+	Protocol                       string
+}
+
+// NewHTTPRequest returns an HTTPRequest with the fields known before r is served or sent.
+// The remaining fields, such as Status or Latency, are up to the caller, see also SetResponse.
+// The RemoteIP is found as described for TrustedProxyHops.
+func NewHTTPRequest(r *http.Request) *HTTPRequest {
+	hr := &HTTPRequest{
+		RequestMethod: r.Method,
+		RequestURL:    requestURL(r),
+		UserAgent:     r.UserAgent(),
+		RemoteIP:      remoteIP(r),
+		Referer:       r.Referer(),
+		Protocol:      r.Proto,
+	}
+
+	if r.ContentLength > 0 {
+		hr.RequestSize = r.ContentLength
+	}
+
+	return hr
+}
+
+// SetResponse fills in the fields known from the response to an HTTP request sent by a client.
+func (hr *HTTPRequest) SetResponse(resp *http.Response) {
+	hr.Status = resp.StatusCode
+	if resp.ContentLength > 0 {
+		hr.ResponseSize = resp.ContentLength
+	}
+	if resp.Proto != "" {
+		hr.Protocol = resp.Proto
+	}
+}
+
+// MarshalJSON encodes hr in the manner of the Cloud Logging API, for example with
+// the Latency as "0.123s" and with the sizes as strings.
+func (hr *HTTPRequest) MarshalJSON() ([]byte, error) {
+	j := struct {
+		RequestMethod                  string `json:"requestMethod,omitempty"`
+		RequestURL                     string `json:"requestUrl,omitempty"`
+		RequestSize                    int64  `json:"requestSize,omitempty,string"`
+		Status                         int    `json:"status,omitempty"`
+		ResponseSize                   int64  `json:"responseSize,omitempty,string"`
+		UserAgent                      string `json:"userAgent,omitempty"`
+		RemoteIP                       string `json:"remoteIp,omitempty"`
+		ServerIP                       string `json:"serverIp,omitempty"`
+		Referer                        string `json:"referer,omitempty"`
+		Latency                        string `json:"latency,omitempty"`
+		CacheLookup                    bool   `json:"cacheLookup,omitempty"`
+		CacheHit                       bool   `json:"cacheHit,omitempty"`
+		CacheValidatedWithOriginServer bool   `json:"cacheValidatedWithOriginServer,omitempty"`
+		CacheFillBytes                 int64  `json:"cacheFillBytes,omitempty,string"`
+		Protocol                       string `json:"protocol,omitempty"`
+	}{
+		RequestMethod:                  hr.RequestMethod,
+		RequestURL:                     hr.RequestURL,
+		RequestSize:                    hr.RequestSize,
+		Status:                         hr.Status,
+		ResponseSize:                   hr.ResponseSize,
+		UserAgent:                      hr.UserAgent,
+		RemoteIP:                       hr.RemoteIP,
+		ServerIP:                       hr.ServerIP,
+		Referer:                        hr.Referer,
+		CacheLookup:                    hr.CacheLookup,
+		CacheHit:                       hr.CacheHit,
+		CacheValidatedWithOriginServer: hr.CacheValidatedWithOriginServer,
+		CacheFillBytes:                 hr.CacheFillBytes,
+		Protocol:                       hr.Protocol,
+	}
+
+	if hr.Latency != 0 {
+		j.Latency = formatDuration(hr.Latency)
+	}
+
+	return marshalJSON(j)
+}
+
+// WithHTTPRequest returns a child Logger, which adds hr as the httpRequest field
+// to every log entry. See also With. Unlike the values of With, hr is encoded anew
+// for every entry, so it must not be modified concurrently with logging.
+func (l *Logger) WithHTTPRequest(hr *HTTPRequest) *Logger {
+	c := l.child(nil)
+	c.httpRequest = hr
+
+	return c
+}
+
+// WithHTTPRequest returns a child of the package-level logger, which adds hr as
+// the httpRequest field to every log entry.
+func WithHTTPRequest(hr *HTTPRequest) *Logger {
+	return std.WithHTTPRequest(hr)
+}
+
+// requestURL returns the absolute URL of a request, either received by a server
+// or sent by a client.
+func requestURL(r *http.Request) string {
+	if r.URL.IsAbs() {
+		return r.URL.String()
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = p
+	}
+
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// TrustedProxyHops is the number of the trailing addresses of the header "X-Forwarded-For"
+// appended by the trusted proxies in front of the server, so that the first of them, counted
+// from the right, is the IP address of the client, see NewHTTPRequest. The leading addresses
+// are up to the client, so they cannot be trusted.
+//
+// The initial value is 1 on Cloud Run, where the Google front end appends the address
+// of the client, as told by the environment variable K_SERVICE, and 0 otherwise, which means
+// the header is ignored. Behind a Google Cloud external Application Load Balancer, which
+// appends the address of the client and then its own, it should be 2. If the header has
+// fewer addresses, the client is the address the request came from.
+var TrustedProxyHops = defaultTrustedProxyHops()
+
+func defaultTrustedProxyHops() int {
+	if os.Getenv("K_SERVICE") != "" {
+		return 1
+	}
+
+	return 0
+}
+
+// remoteIP returns the IP address of the client of a received request, which is taken from
+// the header "X-Forwarded-For" as described for TrustedProxyHops, or the address
+// the request came from.
+func remoteIP(r *http.Request) string {
+	if xff := r.Header["X-Forwarded-For"]; len(xff) != 0 && TrustedProxyHops > 0 {
+		addrs := strings.Split(strings.Join(xff, ","), ",")
+		if len(addrs) >= TrustedProxyHops {
+			if ip := strings.TrimSpace(addrs[len(addrs)-TrustedProxyHops]); net.ParseIP(ip) != nil {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// formatDuration formats d the way the JSON mapping of google.protobuf.Duration does,
+// for example "0.123s".
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	s := strconv.FormatInt(int64(d/time.Second), 10)
+	if ns := int64(d % time.Second); ns != 0 {
+		s += "." + strings.TrimRight(fmt.Sprintf("%09d", ns), "0")
+	}
+
+	return sign + s + "s"
+}
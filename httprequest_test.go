@@ -0,0 +1,111 @@
+package log
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPRequest_MarshalJSON(t *testing.T) {
+	hr := &HTTPRequest{
+		RequestMethod: "GET",
+		RequestURL:    "https://example.com/a&b",
+		Status:        200,
+		ResponseSize:  1234,
+		Latency:       123 * time.Millisecond,
+		CacheHit:      true,
+	}
+	want := `{"requestMethod":"GET","requestUrl":"https://example.com/a&b","status":200,"responseSize":"1234","latency":"0.123s","cacheHit":true}`
+
+	got, err := hr.MarshalJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", got, want)
+	}
+}
+
+func TestLogger_WithHTTPRequest(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"called backend","severity":"INFO","httpRequest":{"requestMethod":"GET","requestUrl":"https://backend.example.com/v1/items?page=2","status":503,"responseSize":"5","latency":"2.5s","protocol":"HTTP/2.0"}}
+`
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	req := httptest.NewRequest("GET", "https://backend.example.com/v1/items?page=2", nil)
+	req.Header.Del("User-Agent")
+	req.RemoteAddr = ""
+	resp := &http.Response{StatusCode: 503, ContentLength: 5, Proto: "HTTP/2.0"}
+
+	// Act
+	hr := NewHTTPRequest(req)
+	hr.Protocol = ""
+	hr.SetResponse(resp)
+	hr.Latency = 2500 * time.Millisecond
+	l.WithHTTPRequest(hr).Info("called backend")
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", buf.String(), wantJSON)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{123 * time.Millisecond, "0.123s"},
+		{3 * time.Second, "3s"},
+		{3*time.Second + time.Nanosecond, "3.000000001s"},
+		{-1500 * time.Millisecond, "-1.5s"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	tests := []struct {
+		name string
+		hops int
+		xff  []string
+		want string
+	}{
+		{name: "no header", hops: 2, want: "192.0.2.1"},
+		{name: "untrusted header", hops: 0, xff: []string{"203.0.113.1"}, want: "192.0.2.1"},
+		{name: "single hop", hops: 1, xff: []string{"203.0.113.1"}, want: "203.0.113.1"},
+		{name: "single hop spoofed", hops: 1, xff: []string{"198.51.100.7, 203.0.113.1"}, want: "203.0.113.1"},
+		{name: "load balancer", hops: 2, xff: []string{"203.0.113.1, 10.0.0.1"}, want: "203.0.113.1"},
+		{name: "load balancer spoofed", hops: 2, xff: []string{"198.51.100.7, 203.0.113.1, 10.0.0.1"}, want: "203.0.113.1"},
+		{name: "several headers", hops: 2, xff: []string{"198.51.100.7", "203.0.113.1,10.0.0.1"}, want: "203.0.113.1"},
+		{name: "too few addresses", hops: 2, xff: []string{"203.0.113.1"}, want: "192.0.2.1"},
+		{name: "not an address", hops: 2, xff: []string{"unknown, 10.0.0.1"}, want: "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			defer func(hops int) { TrustedProxyHops = hops }(TrustedProxyHops)
+			TrustedProxyHops = tt.hops
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+
+			// Act
+			got := remoteIP(req)
+
+			// Assert
+			if got != tt.want {
+				t.Errorf("remoteIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package log
+
+// Debugw logs detailed information that could mainly be used to catch unforeseen problems.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func Debugw(msg string, kv ...interface{}) {
+	logw(SeverityDebug, &std, 2, msg, kv)
+}
+
+// Infow logs routine information, such as ongoing status or performance.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func Infow(msg string, kv ...interface{}) {
+	logw(SeverityInfo, &std, 2, msg, kv)
+}
+
+// Noticew logs normal but significant events, such as start up, shut down, or configuration.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func Noticew(msg string, kv ...interface{}) {
+	logw(SeverityNotice, &std, 2, msg, kv)
+}
+
+// Warningw logs events that might cause problems.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func Warningw(msg string, kv ...interface{}) {
+	logw(SeverityWarning, &std, 2, msg, kv)
+}
+
+// Errorw logs events likely to cause problems.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func Errorw(msg string, kv ...interface{}) {
+	logw(SeverityError, &std, 2, msg, kv)
+}
+
+// Criticalw logs events that cause more severe problems or outages.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func Criticalw(msg string, kv ...interface{}) {
+	logw(SeverityCritical, &std, 2, msg, kv)
+}
+
+// Alertw logs when a person must take an action immediately.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func Alertw(msg string, kv ...interface{}) {
+	logw(SeverityAlert, &std, 2, msg, kv)
+}
+
+// Emergencyw logs when one or more systems are unusable.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func Emergencyw(msg string, kv ...interface{}) {
+	logw(SeverityEmergency, &std, 2, msg, kv)
+}
+
+// Logw logs a message of severity s, which is useful when the severity is computed.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, for example Logw(s, "done", "user", userID, "took", elapsed).
+// See (*Logger).Logw.
+func Logw(s Severity, msg string, kv ...interface{}) {
+	logw(s, &std, 2, msg, kv)
+}
+
+// Debugw logs detailed information that could mainly be used to catch unforeseen problems.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func (l *Logger) Debugw(msg string, kv ...interface{}) {
+	logw(SeverityDebug, l, 2, msg, kv)
+}
+
+// Infow logs routine information, such as ongoing status or performance.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func (l *Logger) Infow(msg string, kv ...interface{}) {
+	logw(SeverityInfo, l, 2, msg, kv)
+}
+
+// Noticew logs normal but significant events, such as start up, shut down, or configuration.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func (l *Logger) Noticew(msg string, kv ...interface{}) {
+	logw(SeverityNotice, l, 2, msg, kv)
+}
+
+// Warningw logs events that might cause problems.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func (l *Logger) Warningw(msg string, kv ...interface{}) {
+	logw(SeverityWarning, l, 2, msg, kv)
+}
+
+// Errorw logs events likely to cause problems.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func (l *Logger) Errorw(msg string, kv ...interface{}) {
+	logw(SeverityError, l, 2, msg, kv)
+}
+
+// Criticalw logs events that cause more severe problems or outages.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func (l *Logger) Criticalw(msg string, kv ...interface{}) {
+	logw(SeverityCritical, l, 2, msg, kv)
+}
+
+// Alertw logs when a person must take an action immediately.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func (l *Logger) Alertw(msg string, kv ...interface{}) {
+	logw(SeverityAlert, l, 2, msg, kv)
+}
+
+// Emergencyw logs when one or more systems are unusable.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, see Logw.
+func (l *Logger) Emergencyw(msg string, kv ...interface{}) {
+	logw(SeverityEmergency, l, 2, msg, kv)
+}
+
+// Logw logs a message of severity s, which is useful when the severity is computed.
+// Arguments kv alternate between string keys and their values, which become the fields
+// of the jsonPayload, for example l.Logw(s, "done", "user", userID, "took", elapsed).
+//
+// The values of the types string, bool, the integers and the floats, time.Time,
+// time.Duration and error are encoded without reflection: a time.Time as an RFC 3339
+// string, a time.Duration as a string such as "1.5s", and an error as its message.
+// The other values are marshaled to JSON. The NaN and the infinite floats become strings.
+//
+// A key that is not a string, or a lone final value, is logged under the key "!BADKEY",
+// the same as by With, so that the mistake is visible in the output. A Field in place of
+// a key stands for a whole key-value pair, e.g. l.Logw(s, "done", log.Err(err), "n", 2).
+func (l *Logger) Logw(s Severity, msg string, kv ...interface{}) {
+	logw(s, l, 2, msg, kv)
+}
+
+func logw(s Severity, l *Logger, depth int, msg string, kv []interface{}) {
+	if !l.Enabled(s) {
+		return
+	}
+
+	b := getBuffer()
+	*b = append(*b, '{')
+	*b = appendKeyValues(*b, kv)
+	*b = append(*b, '}')
+
+	logRawJSON(s, l, depth+1, msg, *b)
+	putBuffer(b)
+}
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// ptrError is an error, whose method Error panics on a nil pointer.
+type ptrError struct {
+	msg string
+}
+
+func (e *ptrError) Error() string { return e.msg }
+
+// ptrStringer is a fmt.Stringer and a json.Marshaler, whose methods panic on a nil pointer.
+type ptrStringer struct {
+	s string
+}
+
+func (s *ptrStringer) String() string { return s.s }
+
+func (s *ptrStringer) MarshalJSON() ([]byte, error) { return json.Marshal(s.s) }
+
+func TestLogger_Logw(t *testing.T) {
+	tests := []struct {
+		name     string
+		kv       []interface{}
+		wantJSON string
+	}{
+		{
+			name:     "none",
+			kv:       nil,
+			wantJSON: `{"message":"m","severity":"INFO"}` + "\n",
+		},
+		{
+			name: "common types",
+			kv: []interface{}{
+				"s", "<a&b>", "b", true, "i", -3, "i8", int8(8), "u64", uint64(1 << 63),
+				"f", 1.5, "f32", float32(0.1), "tiny", 1e-7, "huge", 1e21, "nan", math.NaN(),
+				"t", time.Date(2021, 3, 4, 5, 6, 7, 800, time.UTC), "d", 1500 * time.Millisecond,
+				"err", errors.New("boom"), "nil", nil,
+			},
+			wantJSON: `{"message":"m","severity":"INFO","s":"<a&b>","b":true,"i":-3,"i8":8,"u64":9223372036854775808,` +
+				`"f":1.5,"f32":0.1,"tiny":1e-7,"huge":1e+21,"nan":"NaN",` +
+				`"t":"2021-03-04T05:06:07.0000008Z","d":"1.5s","err":"boom","nil":null}` + "\n",
+		},
+		{
+			name:     "marshaled",
+			kv:       []interface{}{"m", map[string]int{"a": 1}, "ch", make(chan int)},
+			wantJSON: `{"message":"m","severity":"INFO","m":{"a":1},"ch":{"logLibMsg":"cannot marshal the value"}}` + "\n",
+		},
+		{
+			name:     "typed nil",
+			kv:       []interface{}{"err", (*ptrError)(nil), "stringer", (*ptrStringer)(nil)},
+			wantJSON: `{"message":"m","severity":"INFO","err":null,"stringer":null}` + "\n",
+		},
+		{
+			name:     "bad keys",
+			kv:       []interface{}{1, "a", 2, "lone"},
+			wantJSON: `{"message":"m","severity":"INFO","!BADKEY":1,"a":2,"!BADKEY":"lone"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			buf := &bytes.Buffer{}
+			l := New(buf, "", 0)
+
+			// Act
+			l.Logw(SeverityInfo, "m", tt.kv...)
+
+			// Assert
+			if tt.wantJSON != buf.String() {
+				t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestLogger_Warningw(t *testing.T) {
+	// Arrange
+	wantJSON := `{"severity":"WARNING","a":1,"b":"c"}` + "\n"
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0).With("a", 1)
+	l.SetLevel(SeverityWarning)
+
+	// Act
+	l.Infow("dropped", "b", "c")
+	l.Warningw("", "b", "c")
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), wantJSON)
+	}
+}
+
+func BenchmarkInfow(b *testing.B) {
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Infow("test", "user", "alice", "attempt", i, "ok", true, "took", time.Second)
+		buf.Reset()
+	}
+}
@@ -0,0 +1,116 @@
+package log
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// LogfmtEncoder is the Encoder of FormatLogfmt. It writes lines such as:
+//
+//	time=2006-01-02T15:04:05Z level=warning msg="disk almost full" source=main.go:12 disk.free="1.5 GB" trace=4bf92f35
+//
+// The severity becomes the lower-case level, the nested objects and arrays of the fields
+// are flattened to the dotted keys such as "user.roles.0", and the values are quoted
+// when needed, with the same escapes as Go strings.
+type LogfmtEncoder struct{}
+
+// Encode appends e as a line of FormatLogfmt to buf.
+func (LogfmtEncoder) Encode(buf []byte, e *Entry) []byte {
+	start := len(buf)
+	if !e.Time.IsZero() {
+		buf = appendLogfmtKey(buf, start, "time")
+		buf = appendTimestamp(buf, e.Time)
+	}
+	if name := severityName(e.Severity); name != "" {
+		buf = appendLogfmtPair(buf, start, "level", strings.ToLower(name))
+	}
+	buf = appendLogfmtPair(buf, start, "msg", strings.TrimRight(e.Message, "\n"))
+	if e.Prefix != "" {
+		buf = appendLogfmtPair(buf, start, "prefix", e.Prefix)
+	}
+	if e.SourceLocation != nil {
+		buf = appendLogfmtPair(buf, start, "source", e.SourceLocation.File+":"+strconv.Itoa(e.SourceLocation.Line))
+	}
+
+	for _, m := range entryFields(e) {
+		buf = appendLogfmtField(buf, start, m.key, m.value)
+	}
+
+	if e.Trace != "" {
+		buf = appendLogfmtPair(buf, start, "trace", e.Trace[strings.LastIndexByte(e.Trace, '/')+1:])
+	}
+	if e.SpanID != "" {
+		buf = appendLogfmtPair(buf, start, "span", e.SpanID)
+	}
+
+	return append(buf, '\n')
+}
+
+// appendLogfmtField appends the JSON value raw under the key, flattening the objects and
+// the arrays to a pair for each of their elements.
+func appendLogfmtField(dst []byte, start int, key string, raw json.RawMessage) []byte {
+	n := len(dst)
+
+	switch {
+	case len(raw) == 0:
+		return dst
+	case raw[0] == '{':
+		ok := forEachMember(raw, func(k string, v json.RawMessage) bool {
+			dst = appendLogfmtField(dst, start, key+"."+k, v)
+			return true
+		})
+		if ok && len(dst) != n {
+			return dst
+		}
+		dst = dst[:n]
+	case raw[0] == '[':
+		var elems []json.RawMessage
+		if err := json.Unmarshal(raw, &elems); err == nil && len(elems) != 0 {
+			for i, v := range elems {
+				dst = appendLogfmtField(dst, start, key+"."+strconv.Itoa(i), v)
+			}
+			return dst
+		}
+	case raw[0] == '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return appendLogfmtPair(dst, start, key, s)
+		}
+	}
+
+	// The numbers, the bools, null, and the empty objects and arrays are left as they are.
+	dst = appendLogfmtKey(dst, start, key)
+
+	return append(dst, raw...)
+}
+
+// appendLogfmtPair appends the pair key=value, the value quoted if needed.
+func appendLogfmtPair(dst []byte, start int, key, value string) []byte {
+	dst = appendLogfmtKey(dst, start, key)
+	if needsQuoting(value) {
+		return strconv.AppendQuote(dst, value)
+	}
+
+	return append(dst, value...)
+}
+
+// appendLogfmtKey appends the key followed by '=', preceded by a space unless it is
+// the first key of the line, which begins at dst[start:]. The characters not allowed
+// in the keys, such as the spaces, are replaced by underscores.
+func appendLogfmtKey(dst []byte, start int, key string) []byte {
+	if len(dst) > start {
+		dst = append(dst, ' ')
+	}
+	if key == "" {
+		key = "_"
+	}
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+			r = '_'
+		}
+		dst = append(dst, string(r)...)
+	}
+
+	return append(dst, '=')
+}
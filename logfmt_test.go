@@ -0,0 +1,59 @@
+package log
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFormatLogfmt(t *testing.T) {
+	// Arrange
+	ProjectID = "my-project"
+	defer func() { ProjectID = "" }()
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	l.SetFormat(FormatLogfmt)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Cloud-Trace-Context", "00000000000000000000000000000001/1;o=1")
+
+	// Act
+	l.Warningw("disk almost full", "free", "1.5 GB", "dev", "/dev/sda", "pct", 97.5)
+	l.Println("plain")
+	l.ForRequest(req).With("user", "alice").Error("failed")
+	l.Infoj("nested", map[string]interface{}{
+		"user":  map[string]interface{}{"name": "bob", "roles": []string{"admin", "dev"}},
+		"empty": map[string]int{},
+		"none":  nil,
+	})
+	l.Infow("quoted", "q", `say "hi"`, "nl", "a\nb", "eq", "a=b", "bad key", "", "ok", true)
+
+	// Assert
+	want := `level=warning msg="disk almost full" free="1.5 GB" dev=/dev/sda pct=97.5
+level=info msg=plain
+level=error msg=failed user=alice trace=00000000000000000000000000000001 span=0000000000000001
+level=info msg=nested empty={} none=null user.name=bob user.roles.0=admin user.roles.1=dev
+level=info msg=quoted q="say \"hi\"" nl="a\nb" eq="a=b" bad_key="" ok=true
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, want)
+	}
+}
+
+func TestFormatLogfmtWriters(t *testing.T) {
+	// Arrange
+	out, err := &bytes.Buffer{}, &bytes.Buffer{}
+	l := &Logger{out: out, err: err}
+	l.SetFormat(FormatLogfmt)
+
+	// Act
+	l.Notice("started")
+	l.Critical("down")
+
+	// Assert
+	if got, want := out.String(), "level=notice msg=started\n"; got != want {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, want)
+	}
+	if got, want := err.String(), "level=critical msg=down\n"; got != want {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, want)
+	}
+}
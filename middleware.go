@@ -0,0 +1,180 @@
+package log
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Middleware wraps the handler h, so that every request is served with a Logger
+// created by ForRequest and stored in the request context, see FromContext. After h
+// returns, the Logger writes one entry describing the request, with the httpRequest
+// field understood by Cloud Logging, so that the request entry groups together with
+// the entries written by h in the Logs Explorer.
+//
+// The severity of the request entry is ERROR for the 5xx status codes, WARNING for
+// the 4xx status codes, and INFO otherwise.
+func Middleware(h http.Handler) http.Handler {
+	return std.Middleware(h)
+}
+
+// Middleware is like the package-level Middleware, except the request Loggers are
+// the children of l, see (*Logger).ForRequest.
+func (l *Logger) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rl := l.ForRequest(r)
+		rw := &responseWriter{ResponseWriter: w}
+
+		h.ServeHTTP(rw.wrap(), r.WithContext(NewContext(r.Context(), rl)))
+
+		hr := NewHTTPRequest(r)
+		hr.Status = rw.status
+		if hr.Status == 0 {
+			hr.Status = http.StatusOK
+		}
+		hr.ResponseSize = rw.size
+		hr.Latency = time.Since(start)
+
+		if s := statusSeverity(hr.Status); rl.Enabled(s) {
+			// The source location would always be this function, so it is omitted.
+			hl := rl.WithHTTPRequest(hr)
+			msg, t, prefix := hl.header("")
+			writeRawJSON(s, hl, msg, t, prefix, nil, hl.pcErrorReport(s, 0), nil)
+		}
+	})
+}
+
+// statusSeverity returns the severity of a request entry, given its HTTP status code.
+func statusSeverity(status int) Severity {
+	switch {
+	case status >= 500:
+		return SeverityError
+	case status >= 400:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// responseWriter records the status code and the size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+
+	return n, err
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for the sake of http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// wrap returns w extended by the methods of http.Flusher, http.Hijacker and io.ReaderFrom,
+// which the wrapped http.ResponseWriter implements, but not the others, so that a handler
+// checking for them by a type assertion is not mistaken.
+func (w *responseWriter) wrap() http.ResponseWriter {
+	_, f := w.ResponseWriter.(http.Flusher)
+	_, h := w.ResponseWriter.(http.Hijacker)
+	_, r := w.ResponseWriter.(io.ReaderFrom)
+
+	switch {
+	case f && h && r:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, flusher{w}, hijacker{w}, readerFrom{w}}
+	case f && h:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+		}{w, flusher{w}, hijacker{w}}
+	case f && r:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{w, flusher{w}, readerFrom{w}}
+	case h && r:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{w, hijacker{w}, readerFrom{w}}
+	case f:
+		return struct {
+			*responseWriter
+			http.Flusher
+		}{w, flusher{w}}
+	case h:
+		return struct {
+			*responseWriter
+			http.Hijacker
+		}{w, hijacker{w}}
+	case r:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+		}{w, readerFrom{w}}
+	default:
+		return w
+	}
+}
+
+type flusher struct {
+	w *responseWriter
+}
+
+func (f flusher) Flush() {
+	if f.w.status == 0 {
+		f.w.status = http.StatusOK
+	}
+	f.w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijacker struct {
+	w *responseWriter
+}
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h.w.status == 0 {
+		h.w.status = http.StatusSwitchingProtocols
+	}
+
+	return h.w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type readerFrom struct {
+	w *responseWriter
+}
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	if r.w.status == 0 {
+		r.w.status = http.StatusOK
+	}
+	n, err := r.w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	r.w.size += n
+
+	return n, err
+}
@@ -0,0 +1,220 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_Middleware(t *testing.T) {
+	// Arrange
+	ProjectID = "my-project"
+	defer func() { ProjectID = "" }()
+	defer func(hops int) { TrustedProxyHops = hops }(TrustedProxyHops)
+	TrustedProxyHops = 2
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		InfoCtx(r.Context(), "inside")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, "not here")
+	}))
+	req := httptest.NewRequest("POST", "http://example.com/a?b=c", strings.NewReader("body"))
+	req.Header.Set("X-Cloud-Trace-Context", "00000000000000000000000000000001/1;o=1")
+	req.Header.Set("User-Agent", "test/1.0")
+	req.Header.Set("Referer", "http://example.com/")
+	req.Header.Set("X-Forwarded-For", "203.0.113.1, 10.0.0.1")
+
+	// Act
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Assert
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+	wantInside := `{"message":"inside","severity":"INFO","logging.googleapis.com/trace":"projects/my-project/traces/00000000000000000000000000000001","logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace_sampled":true}`
+	if lines[0] != wantInside {
+		t.Errorf("unexpected handler entry, got:\n%s\nexpected:\n%s\n", lines[0], wantInside)
+	}
+
+	var got struct {
+		Severity    string                 `json:"severity"`
+		Trace       string                 `json:"logging.googleapis.com/trace"`
+		HTTPRequest map[string]interface{} `json:"httpRequest"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatalf("request entry is not a valid JSON: %v\n%s", err, lines[1])
+	}
+	if got.Severity != "WARNING" {
+		t.Errorf("severity = %q, want WARNING", got.Severity)
+	}
+	if got.Trace != "projects/my-project/traces/00000000000000000000000000000001" {
+		t.Errorf("trace = %q", got.Trace)
+	}
+	latency, _ := got.HTTPRequest["latency"].(string)
+	if _, err := time.ParseDuration(latency); err != nil {
+		t.Errorf("latency %q: %v", latency, err)
+	}
+	delete(got.HTTPRequest, "latency")
+	want := map[string]interface{}{
+		"requestMethod": "POST",
+		"requestUrl":    "http://example.com/a?b=c",
+		"requestSize":   "4",
+		"status":        float64(404),
+		"responseSize":  "8",
+		"userAgent":     "test/1.0",
+		"remoteIp":      "203.0.113.1",
+		"referer":       "http://example.com/",
+		"protocol":      "HTTP/1.1",
+	}
+	for k, v := range want {
+		if got.HTTPRequest[k] != v {
+			t.Errorf("httpRequest.%s = %#v, want %#v", k, got.HTTPRequest[k], v)
+		}
+	}
+	if len(got.HTTPRequest) != len(want) {
+		t.Errorf("unexpected httpRequest: %v", got.HTTPRequest)
+	}
+}
+
+func TestLogger_MiddlewareImplicitStatus(t *testing.T) {
+	// Arrange
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if FromContext(r.Context()).root() != l {
+			t.Errorf("the request Logger is not a child of l")
+		}
+	}))
+
+	// Act
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	// Assert
+	if !strings.Contains(buf.String(), `"severity":"INFO"`) || !strings.Contains(buf.String(), `"status":200`) {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+// optionalResponseWriter returns rec as an http.ResponseWriter, which implements
+// http.Hijacker and io.ReaderFrom, if asked, but not http.Flusher.
+func optionalResponseWriter(rec *httptest.ResponseRecorder, hijacker, readerFrom bool) http.ResponseWriter {
+	w := struct{ http.ResponseWriter }{rec}
+	switch {
+	case hijacker && readerFrom:
+		return struct {
+			http.ResponseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{w, recorderHijacker{}, rec.Body}
+	case hijacker:
+		return struct {
+			http.ResponseWriter
+			http.Hijacker
+		}{w, recorderHijacker{}}
+	case readerFrom:
+		return struct {
+			http.ResponseWriter
+			io.ReaderFrom
+		}{w, rec.Body}
+	default:
+		return w
+	}
+}
+
+type recorderHijacker struct{}
+
+func (recorderHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("not hijackable")
+}
+
+func TestLogger_MiddlewareOptionalInterfaces(t *testing.T) {
+	tests := []struct {
+		name                          string
+		w                             http.ResponseWriter
+		flusher, hijacker, readerFrom bool
+	}{
+		{name: "none", w: optionalResponseWriter(httptest.NewRecorder(), false, false)},
+		{name: "flusher", w: httptest.NewRecorder(), flusher: true},
+		{name: "hijacker", w: optionalResponseWriter(httptest.NewRecorder(), true, false), hijacker: true},
+		{name: "readerFrom", w: optionalResponseWriter(httptest.NewRecorder(), false, true), readerFrom: true},
+		{name: "hijacker and readerFrom", w: optionalResponseWriter(httptest.NewRecorder(), true, true), hijacker: true, readerFrom: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			l := New(ioutil.Discard, "", 0)
+			var flusher, hijacker, readerFrom bool
+			h := l.RecoverMiddleware(l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, flusher = w.(http.Flusher)
+				_, hijacker = w.(http.Hijacker)
+				_, readerFrom = w.(io.ReaderFrom)
+			})))
+
+			// Act
+			h.ServeHTTP(tt.w, httptest.NewRequest("GET", "/", nil))
+
+			// Assert
+			if flusher != tt.flusher || hijacker != tt.hijacker || readerFrom != tt.readerFrom {
+				t.Errorf("Flusher %v, Hijacker %v, ReaderFrom %v, want %v, %v, %v",
+					flusher, hijacker, readerFrom, tt.flusher, tt.hijacker, tt.readerFrom)
+			}
+		})
+	}
+}
+
+func TestLogger_MiddlewareReadFrom(t *testing.T) {
+	// Arrange
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.(io.ReaderFrom).ReadFrom(strings.NewReader("hello"))
+	}))
+	rec := httptest.NewRecorder()
+
+	// Act
+	h.ServeHTTP(optionalResponseWriter(rec, false, true), httptest.NewRequest("GET", "/", nil))
+
+	// Assert
+	if rec.Body.String() != "hello" {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+	if !strings.Contains(buf.String(), `"status":200`) || !strings.Contains(buf.String(), `"responseSize":"5"`) {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestLogger_MiddlewareNoSourceLocation(t *testing.T) {
+	// Arrange
+	buf := &bytes.Buffer{}
+	l := New(buf, "", Lshortfile)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		InfoCtx(r.Context(), "inside")
+	}))
+
+	// Act
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	// Assert
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[0], `"logging.googleapis.com/sourceLocation":{"file":"middleware_test.go"`) {
+		t.Errorf("the handler entry has no source location:\n%s", lines[0])
+	}
+	if strings.Contains(lines[1], "sourceLocation") {
+		t.Errorf("the request entry has a source location:\n%s", lines[1])
+	}
+}
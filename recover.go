@@ -0,0 +1,161 @@
+package log
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// PanicPolicy tells what RecoverMiddleware and Go do after logging a recovered panic.
+type PanicPolicy int32
+
+const (
+	// PanicRepanic panics again with the recovered value, so that the program crashes
+	// the same as without the recovery, only with the panic logged as a single entry.
+	// It is the default.
+	PanicRepanic PanicPolicy = iota
+	// PanicContinue carries on: RecoverMiddleware responds with the status code 500,
+	// unless the handler has already written the response header, and Go lets the
+	// goroutine end.
+	PanicContinue
+	// PanicExit exits the program with the status code 2, the same as an unrecovered panic,
+	// after running the functions registered by AtExit, see Exit.
+	PanicExit
+)
+
+// SetPanicPolicy sets what RecoverMiddleware and Go of l do after logging a recovered panic.
+// It is safe to call SetPanicPolicy while other goroutines are logging.
+func (l *Logger) SetPanicPolicy(p PanicPolicy) {
+	l.panicPolicy.store(int32(p))
+}
+
+// PanicPolicy returns what RecoverMiddleware and Go of l do after logging a recovered panic.
+func (l *Logger) PanicPolicy() PanicPolicy {
+	return PanicPolicy(l.load(func(l *Logger) *setting { return &l.panicPolicy }))
+}
+
+// SetPanicPolicy sets what the package-level RecoverMiddleware and Go do after logging
+// a recovered panic.
+func SetPanicPolicy(p PanicPolicy) {
+	std.SetPanicPolicy(p)
+}
+
+// RecoverMiddleware wraps the handler h, so that a panic in h is logged as one entry of
+// severity CRITICAL, before being dealt with according to the PanicPolicy, see
+// SetPanicPolicy. The message of the entry has the panic value and the stack trace of the
+// goroutine, in the same format as the Go runtime prints them when crashing, which Cloud
+// Error Reporting recognizes.
+//
+// The entry is logged through the Logger stored in the request context, so that it traces
+// back to the request, or through a Logger created by ForRequest, if there is none. Wrap
+// it as Middleware(RecoverMiddleware(h)) for the former.
+//
+// The panics with the value http.ErrAbortHandler are not logged, as net/http uses them
+// to abort a response silently.
+func RecoverMiddleware(h http.Handler) http.Handler {
+	return std.RecoverMiddleware(h)
+}
+
+// RecoverMiddleware is like the package-level RecoverMiddleware, except the panics are
+// logged through l, or the Logger in the request context, and handled according to the
+// PanicPolicy of l.
+func (l *Logger) RecoverMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			rl, ok := r.Context().Value(contextKey{}).(*Logger)
+			if !ok || rl == nil {
+				rl = l.ForRequest(r)
+			}
+			logPanic(rl, v)
+
+			switch l.PanicPolicy() {
+			case PanicContinue:
+				if rw.status == 0 {
+					http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			case PanicExit:
+				l.exitWith(2)
+			default:
+				panic(v)
+			}
+		}()
+
+		h.ServeHTTP(rw.wrap(), r)
+	})
+}
+
+// Go runs f in a new goroutine, so that a panic in f is logged as one entry of severity
+// CRITICAL, before being dealt with according to the PanicPolicy, see SetPanicPolicy and
+// RecoverMiddleware.
+func Go(f func()) {
+	std.Go(f)
+}
+
+// Go runs f in a new goroutine, so that a panic in f is logged through l, and handled
+// according to the PanicPolicy of l. See the package-level Go.
+func (l *Logger) Go(f func()) {
+	go func() {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			logPanic(l, v)
+
+			switch l.PanicPolicy() {
+			case PanicContinue:
+			case PanicExit:
+				l.exitWith(2)
+			default:
+				panic(v)
+			}
+		}()
+
+		f()
+	}()
+}
+
+// logPanic logs the recovered panic value v together with the stack trace. It must be
+// called by the deferred function, which recovered v.
+func logPanic(l *Logger, v interface{}) {
+	if !l.Enabled(SeverityCritical) {
+		return
+	}
+
+	// The stack of the deferred function still has the frames, which panicked.
+	msg := fmt.Sprintf("panic: %v\n\n%s", v, trimPanicFrames(stackTrace(2)))
+	logs(SeverityCritical, l, 2, msg)
+	_ = l.Flush()
+}
+
+// trimPanicFrames removes the frames of the deferred function and of the runtime function
+// panic from the stack trace, leaving the function which panicked on top, the same as the
+// Go runtime prints the stack trace when crashing.
+func trimPanicFrames(stack string) string {
+	i := strings.Index(stack, "\npanic(")
+	if i < 0 {
+		return stack
+	}
+
+	header := strings.IndexByte(stack, '\n') + 1
+	rest := stack[i+1:]
+	for n := 0; n < 2; n++ {
+		nl := strings.IndexByte(rest, '\n')
+		if nl < 0 {
+			return stack
+		}
+		rest = rest[nl+1:]
+	}
+
+	return stack[:header] + rest
+}
@@ -0,0 +1,130 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogger_RecoverMiddleware(t *testing.T) {
+	// Arrange
+	ProjectID = "my-project"
+	defer func() { ProjectID = "" }()
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	l.SetPanicPolicy(PanicContinue)
+	h := l.Middleware(l.RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Cloud-Trace-Context", "00000000000000000000000000000001/1;o=1")
+	rec := httptest.NewRecorder()
+
+	// Act
+	h.ServeHTTP(rec, req)
+
+	// Assert
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+	var got struct {
+		Message  string `json:"message"`
+		Severity string `json:"severity"`
+		Trace    string `json:"logging.googleapis.com/trace"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("panic entry is not a valid JSON: %v\n%s", err, lines[0])
+	}
+	if got.Severity != "CRITICAL" {
+		t.Errorf("severity = %q, want CRITICAL", got.Severity)
+	}
+	if got.Trace != "projects/my-project/traces/00000000000000000000000000000001" {
+		t.Errorf("trace = %q", got.Trace)
+	}
+	msg := strings.Split(got.Message, "\n")
+	if len(msg) < 4 || msg[0] != "panic: boom" || msg[1] != "" || !strings.HasPrefix(msg[2], "goroutine ") ||
+		!strings.HasPrefix(msg[3], "github.com/apsystole/log.TestLogger_RecoverMiddleware.") {
+		t.Errorf("unexpected message:\n%s", got.Message)
+	}
+	if !strings.Contains(lines[1], `"status":500`) {
+		t.Errorf("unexpected request entry:\n%s", lines[1])
+	}
+}
+
+func TestLogger_RecoverMiddlewareRepanic(t *testing.T) {
+	// Arrange
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	h := l.RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	// Act
+	func() {
+		defer func() {
+			// Assert
+			if v := recover(); v != "boom" {
+				t.Errorf("recovered %v, want boom", v)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	// Assert
+	if !strings.HasPrefix(buf.String(), `{"message":"panic: boom\n\ngoroutine `) {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestLogger_RecoverMiddlewareAbort(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	h := l.RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	func() {
+		defer func() { _ = recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	if buf.Len() != 0 {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+// chanWriter sends every write to the channel.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+
+	return len(p), nil
+}
+
+func TestLogger_Go(t *testing.T) {
+	// Arrange
+	w := make(chanWriter, 1)
+	l := New(w, "", 0).With("job", "a")
+	l.SetPanicPolicy(PanicContinue)
+
+	// Act
+	l.Go(func() {
+		var m map[string]int
+		m["b"] = 1
+	})
+	got := <-w
+
+	// Assert
+	if !strings.HasPrefix(got, `{"message":"panic: assignment to entry in nil map\n\ngoroutine `) ||
+		!strings.HasSuffix(got, `,"severity":"CRITICAL","job":"a"}`+"\n") {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
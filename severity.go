@@ -0,0 +1,149 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Severity is the severity of a log entry. The numeric values are the same as the
+// LogSeverity values of the Google Cloud Logging API v2, so a higher value means
+// a more severe entry. An entry of a Severity other than the named ones is logged
+// without a severity, the same as of the zero Severity, which means DEFAULT.
+type Severity int32
+
+// The severities, from the least to the most severe.
+const (
+	SeverityDebug Severity = (iota + 1) * 100
+	SeverityInfo
+	SeverityNotice
+	SeverityWarning
+	SeverityError
+	SeverityCritical
+	SeverityAlert
+	SeverityEmergency
+)
+
+// MarshalJSON encodes s as a JSON string, for example "WARNING".
+func (s Severity) MarshalJSON() ([]byte, error) {
+	switch s {
+	default:
+		return []byte(`"UNKNOWN"`), fmt.Errorf("unknown severity: %d", s)
+	case SeverityDebug:
+		return []byte(`"DEBUG"`), nil
+	case SeverityInfo:
+		return []byte(`"INFO"`), nil
+	case SeverityNotice:
+		return []byte(`"NOTICE"`), nil
+	case SeverityWarning:
+		return []byte(`"WARNING"`), nil
+	case SeverityError:
+		return []byte(`"ERROR"`), nil
+	case SeverityCritical:
+		return []byte(`"CRITICAL"`), nil
+	case SeverityAlert:
+		return []byte(`"ALERT"`), nil
+	case SeverityEmergency:
+		return []byte(`"EMERGENCY"`), nil
+	}
+}
+
+// IsErrorish returns true for severity ERROR and above it.
+func (s Severity) IsErrorish() bool {
+	return s >= SeverityError
+}
+
+// severityNames maps the lower-case names and abbreviations accepted by ParseSeverity.
+var severityNames = map[string]Severity{
+	"default":   0,
+	"debug":     SeverityDebug,
+	"info":      SeverityInfo,
+	"notice":    SeverityNotice,
+	"warning":   SeverityWarning,
+	"warn":      SeverityWarning,
+	"error":     SeverityError,
+	"err":       SeverityError,
+	"critical":  SeverityCritical,
+	"crit":      SeverityCritical,
+	"alert":     SeverityAlert,
+	"emergency": SeverityEmergency,
+	"emerg":     SeverityEmergency,
+}
+
+// String returns the Cloud Logging name of s, such as "WARNING". The zero Severity
+// is "DEFAULT", meaning no severity.
+func (s Severity) String() string {
+	if s == 0 {
+		return "DEFAULT"
+	}
+
+	b, err := s.MarshalJSON()
+	if err != nil {
+		return "Severity(" + strconv.Itoa(int(s)) + ")"
+	}
+
+	return string(b[1 : len(b)-1])
+}
+
+// ParseSeverity parses a severity name such as "WARNING", "warning" or "warn", or a numeric
+// Cloud Logging LogSeverity value such as "400". The names are case-insensitive and also
+// include the abbreviations "err", "crit" and "emerg".
+func ParseSeverity(str string) (Severity, error) {
+	str = strings.TrimSpace(str)
+
+	if s, ok := severityNames[strings.ToLower(str)]; ok {
+		return s, nil
+	}
+
+	if n, err := strconv.Atoi(str); err == nil && n >= 0 && n <= int(SeverityEmergency) && n%100 == 0 {
+		return Severity(n), nil
+	}
+
+	return 0, fmt.Errorf("unknown severity: %q", str)
+}
+
+// MarshalText encodes s as its name, for example "WARNING".
+func (s Severity) MarshalText() ([]byte, error) {
+	if s == 0 {
+		return []byte("DEFAULT"), nil
+	}
+
+	b, err := s.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return b[1 : len(b)-1], nil
+}
+
+// UnmarshalText decodes a severity name or number in the manner of ParseSeverity.
+func (s *Severity) UnmarshalText(text []byte) error {
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = parsed
+
+	return nil
+}
+
+// UnmarshalJSON decodes either a JSON string in the manner of ParseSeverity,
+// or a JSON number holding a Cloud Logging LogSeverity value.
+func (s *Severity) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+
+	var str string
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &str); err != nil {
+			return err
+		}
+	} else {
+		str = string(b)
+	}
+
+	return s.UnmarshalText([]byte(str))
+}
@@ -0,0 +1,145 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		want    Severity
+		wantErr bool
+	}{
+		{name: "upper case", arg: "WARNING", want: SeverityWarning},
+		{name: "lower case", arg: "warning", want: SeverityWarning},
+		{name: "abbreviation", arg: "WARN", want: SeverityWarning},
+		{name: "err", arg: "err", want: SeverityError},
+		{name: "spaces", arg: " info ", want: SeverityInfo},
+		{name: "numeric", arg: "600", want: SeverityCritical},
+		{name: "default", arg: "DEFAULT", want: 0},
+		{name: "numeric out of range", arg: "900", wantErr: true},
+		{name: "numeric not a value", arg: "150", wantErr: true},
+		{name: "unknown", arg: "verbose", wantErr: true},
+		{name: "empty", arg: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSeverity(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSeverity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSeverity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeverity_String(t *testing.T) {
+	tests := []struct {
+		s    Severity
+		want string
+	}{
+		{SeverityDebug, "DEBUG"},
+		{SeverityEmergency, "EMERGENCY"},
+		{0, "DEFAULT"},
+		{123, "Severity(123)"},
+	}
+	for _, tt := range tests {
+		if got := tt.s.String(); got != tt.want {
+			t.Errorf("Severity(%d).String() = %q, want %q", int(tt.s), got, tt.want)
+		}
+	}
+}
+
+func TestSeverity_UnmarshalJSON(t *testing.T) {
+	var cfg struct {
+		A Severity
+		B Severity
+		C Severity
+	}
+	in := `{"A":"notice","B":700,"C":null}`
+
+	if err := json.Unmarshal([]byte(in), &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.A != SeverityNotice || cfg.B != SeverityAlert || cfg.C != 0 {
+		t.Errorf("unexpected result: %+v", cfg)
+	}
+	if err := json.Unmarshal([]byte(`{"A":"loud"}`), &cfg); err == nil {
+		t.Errorf("expected an error for an unknown severity")
+	}
+}
+
+func TestSeverity_Ordering(t *testing.T) {
+	order := []Severity{
+		SeverityDebug,
+		SeverityInfo,
+		SeverityNotice,
+		SeverityWarning,
+		SeverityError,
+		SeverityCritical,
+		SeverityAlert,
+		SeverityEmergency,
+	}
+	for i, s := range order {
+		if int(s) != (i+1)*100 {
+			t.Errorf("%v = %d, want the Cloud Logging value %d", s, int(s), (i+1)*100)
+		}
+		if s.IsErrorish() != (s >= SeverityError) {
+			t.Errorf("%v.IsErrorish() = %v", s, s.IsErrorish())
+		}
+	}
+}
+
+func TestLogger_Logf(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"disk 97% full","severity":"ALERT"}
+`
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+
+	// Act
+	l.Logf(SeverityAlert, "disk %d%% full", 97)
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), wantJSON)
+	}
+}
+
+func TestLogger_LogUnknownSeverity(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l *Logger, s Severity)
+		want string
+	}{
+		{"Log", func(l *Logger, s Severity) { l.Log(s, "x") }, `{"message":"x"}`},
+		{"Logln", func(l *Logger, s Severity) { l.Logln(s, "x") }, `{"message":"x\n"}`},
+		{"Logf", func(l *Logger, s Severity) { l.Logf(s, "%s", "x") }, `{"message":"x"}`},
+		{"Logj", func(l *Logger, s Severity) { l.Logj(s, "x", map[string]int{"n": 1}) }, `{"message":"x","n":1}`},
+		{"Logw", func(l *Logger, s Severity) { l.Logw(s, "x", "n", 1) }, `{"message":"x","n":1}`},
+		{"LogFields", func(l *Logger, s Severity) { l.LogFields(s, "x", Int("n", 1)) }, `{"message":"x","n":1}`},
+		{"LogErr", func(l *Logger, s Severity) { l.LogErr(s, nil, "x") }, `{"message":"x"}`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			buf := &bytes.Buffer{}
+			l := New(buf, "", 0)
+
+			// Act
+			tt.log(l, Severity(150))
+
+			// Assert
+			if got, want := buf.String(), tt.want+"\n"; got != want {
+				t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package log
+
+import (
+	"io"
+	"sync"
+)
+
+// Sink receives the entries of a Logger, fully built, instead of its writers, see SetSink.
+// The Sinks can be combined, for example to write every entry to os.Stdout, and the entries
+// of severity WARNING and above to a file as well:
+//
+//	log.SetSink(log.NewTeeSink(
+//		log.NewWriterSink(os.Stdout, nil),
+//		log.NewFilterSink(log.NewWriterSink(f, log.JSONEncoder{}), func(e *log.Entry) bool {
+//			return e.Severity >= log.SeverityWarning
+//		}),
+//	))
+//
+// A Sink must be safe for concurrent use. If it buffers the entries, it should have
+// a method Flush() error, which (*Logger).Flush calls.
+type Sink interface {
+	// WriteEntry writes the entry e. Neither e nor its Fields may be retained after
+	// WriteEntry returns.
+	WriteEntry(e *Entry) error
+}
+
+// sinkValue is the Sink stored by SetSink, as atomic.Value does not store nil, nor
+// the values of different types.
+type sinkValue struct {
+	sink Sink
+}
+
+// SetSink sets the Sink, which receives all the entries logged through l, instead of
+// the writers set by New or SetOutput. The Encoder and the Format of l do not apply, as
+// the Sink encodes the entries itself. Setting it to nil restores the writers. A child
+// Logger, such as created by With or ForRequest, shares its Sink with its parent, so
+// SetSink affects both. It is safe to call SetSink while other goroutines are logging.
+func (l *Logger) SetSink(s Sink) {
+	l.root().sink.Store(sinkValue{s})
+}
+
+// Sink returns the Sink of l set by SetSink, or nil.
+func (l *Logger) Sink() Sink {
+	v, _ := l.root().sink.Load().(sinkValue)
+
+	return v.sink
+}
+
+// SetSink sets the Sink of the package-level logger. See (*Logger).SetSink.
+func SetSink(s Sink) {
+	std.SetSink(s)
+}
+
+// writeSink is s.WriteEntry(e), except it passes a copy of e, so that e does not escape
+// to the heap.
+func writeSink(s Sink, e *Entry) error {
+	c := *e
+
+	return s.WriteEntry(&c)
+}
+
+// flushSink flushes s, if it has a method Flush() error.
+func flushSink(s Sink) error {
+	if f, ok := s.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
+// NewWriterSink returns a Sink, which encodes the entries by enc and writes each of them
+// to w by a single Write. If enc is nil, it is selected for w in the manner of FormatAuto,
+// see SetFormat. Its method Flush flushes w, if w has a method Flush() error.
+func NewWriterSink(w io.Writer, enc Encoder) Sink {
+	if enc == nil {
+		// The format of the writer does not change, unlike the one of a Logger.
+		enc = formatEncoder(resolveFormat(FormatAuto, w))
+	}
+
+	return &writerSink{w: w, enc: enc}
+}
+
+type writerSink struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc Encoder
+}
+
+func (s *writerSink) WriteEntry(e *Entry) error {
+	b := getBuffer()
+	*b = encode(s.enc, *b, e)
+
+	s.mu.Lock()
+	_, err := s.w.Write(*b)
+	s.mu.Unlock()
+
+	putBuffer(b)
+
+	return err
+}
+
+func (s *writerSink) Flush() error {
+	return flushWriter(s.w)
+}
+
+// NewSeveritySink returns a Sink, which routes the entries of severity ERROR and above
+// to errs, and the rest to out, the same as a Logger does with its writers. Either of
+// them may be nil to discard the entries.
+func NewSeveritySink(out, errs Sink) Sink {
+	return &severitySink{out: out, err: errs}
+}
+
+type severitySink struct {
+	out, err Sink
+}
+
+func (s *severitySink) WriteEntry(e *Entry) error {
+	t := s.out
+	if e.Severity.IsErrorish() {
+		t = s.err
+	}
+	if t == nil {
+		return nil
+	}
+
+	return t.WriteEntry(e)
+}
+
+func (s *severitySink) Flush() error {
+	return flushSinks(s.out, s.err)
+}
+
+// NewTeeSink returns a Sink, which writes every entry to all the sinks, in their order.
+// It returns the first error of them, if any, after writing to all of them.
+func NewTeeSink(sinks ...Sink) Sink {
+	return teeSink(append([]Sink(nil), sinks...))
+}
+
+type teeSink []Sink
+
+func (s teeSink) WriteEntry(e *Entry) error {
+	var err error
+	for _, t := range s {
+		if werr := t.WriteEntry(e); err == nil {
+			err = werr
+		}
+	}
+
+	return err
+}
+
+func (s teeSink) Flush() error {
+	return flushSinks(s...)
+}
+
+// NewFilterSink returns a Sink, which writes to s only the entries, for which keep
+// returns true. The function keep must not modify the entries.
+func NewFilterSink(s Sink, keep func(e *Entry) bool) Sink {
+	return &filterSink{sink: s, keep: keep}
+}
+
+type filterSink struct {
+	sink Sink
+	keep func(e *Entry) bool
+}
+
+func (s *filterSink) WriteEntry(e *Entry) error {
+	if !s.keep(e) {
+		return nil
+	}
+
+	return s.sink.WriteEntry(e)
+}
+
+func (s *filterSink) Flush() error {
+	return flushSink(s.sink)
+}
+
+// flushSinks flushes all the sinks, ignoring the nil ones, and returns the first error.
+func flushSinks(sinks ...Sink) error {
+	var err error
+	for _, s := range sinks {
+		if s == nil {
+			continue
+		}
+		if ferr := flushSink(s); err == nil {
+			err = ferr
+		}
+	}
+
+	return err
+}
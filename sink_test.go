@@ -0,0 +1,104 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestLogger_SetSink(t *testing.T) {
+	// Arrange
+	out, all, warnings := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	l := New(out, "", 0)
+	l.SetSink(NewTeeSink(
+		NewWriterSink(all, JSONEncoder{}),
+		NewFilterSink(NewWriterSink(warnings, LogfmtEncoder{}), func(e *Entry) bool {
+			return e.Severity >= SeverityWarning
+		}),
+	))
+	c := l.With("user", "alice")
+
+	// Act
+	l.Info("started")
+	c.Warning("disk almost full")
+	c.Critical("down")
+	l.SetSink(nil)
+	l.Info("restored")
+
+	// Assert
+	wantAll := `{"message":"started","severity":"INFO"}
+{"message":"disk almost full","severity":"WARNING","user":"alice"}
+{"message":"down","severity":"CRITICAL","user":"alice"}
+`
+	if got := all.String(); got != wantAll {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, wantAll)
+	}
+	wantWarnings := `level=warning msg="disk almost full" user=alice
+level=critical msg=down user=alice
+`
+	if got := warnings.String(); got != wantWarnings {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, wantWarnings)
+	}
+	wantOut := `{"message":"restored","severity":"INFO"}
+`
+	if got := out.String(); got != wantOut {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, wantOut)
+	}
+}
+
+func TestNewSeveritySink(t *testing.T) {
+	// Arrange
+	out, errs := &bytes.Buffer{}, &bytes.Buffer{}
+	l := New(nil, "", 0)
+	l.SetSink(NewSeveritySink(NewWriterSink(out, JSONEncoder{}), NewWriterSink(errs, JSONEncoder{})))
+
+	// Act
+	l.Notice("started")
+	l.Error("failed")
+
+	// Assert
+	if got, want := out.String(), `{"message":"started","severity":"NOTICE"}`+"\n"; got != want {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, want)
+	}
+	if got, want := errs.String(), `{"message":"failed","severity":"ERROR"}`+"\n"; got != want {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", got, want)
+	}
+}
+
+// testSink is a Sink, which counts the entries and the flushes, and fails with err.
+type testSink struct {
+	entries, flushes int
+	err              error
+}
+
+func (s *testSink) WriteEntry(e *Entry) error {
+	s.entries++
+	return s.err
+}
+
+func (s *testSink) Flush() error {
+	s.flushes++
+	return s.err
+}
+
+func TestNewTeeSink(t *testing.T) {
+	// Arrange
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+	a, b, c := &testSink{}, &testSink{err: errFirst}, &testSink{err: errSecond}
+	l := New(nil, "", 0)
+	l.SetSink(NewTeeSink(a, NewSeveritySink(b, nil), NewFilterSink(c, func(*Entry) bool { return true })))
+
+	// Act
+	err := logs(SeverityInfo, l, 1, "hello")
+	ferr := l.Flush()
+
+	// Assert
+	if err != errFirst || ferr != errFirst {
+		t.Errorf("errors %v and %v, want %v", err, ferr, errFirst)
+	}
+	for i, s := range []*testSink{a, b, c} {
+		if s.entries != 1 || s.flushes != 1 {
+			t.Errorf("sink %d got %d entries and %d flushes, want 1 and 1", i, s.entries, s.flushes)
+		}
+	}
+}
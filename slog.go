@@ -0,0 +1,233 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+)
+
+// The slog levels between the ones defined by the "log/slog" package, which map to the
+// Cloud Logging severities without a slog counterpart. See NewSlogHandler.
+const (
+	SlogLevelNotice    slog.Level = 2
+	SlogLevelCritical  slog.Level = 12
+	SlogLevelAlert     slog.Level = 16
+	SlogLevelEmergency slog.Level = 20
+)
+
+// SlogHandler is a slog.Handler, which writes the records through a Logger as the
+// Cloud Logging entries. Create it with NewSlogHandler.
+type SlogHandler struct {
+	l *Logger
+	// pre are the encoded JSON object members of WithAttrs, which may have opened groups.
+	pre []byte
+	// opened is the number of groups opened in pre, to be closed by Handle.
+	opened int
+	// pending are the groups of WithGroup, which are not opened in pre until an attribute
+	// appears in them, as the empty groups are omitted.
+	pending []string
+}
+
+// NewSlogHandler returns a slog.Handler, which writes the records through l, or through
+// the package-level logger if l is nil. The attributes and the groups become the fields
+// of the jsonPayload, the record time becomes the "timestamp" field, and the program
+// counter of the record becomes the source location if l adds one, see SetSourceLocation.
+// Handle takes the trace from the Logger stored in its context, see NewContext and
+// Middleware, so that the records trace back to the HTTP request.
+//
+// The slog levels map to the severities as follows:
+//
+//	level < slog.LevelInfo (0)            DEBUG
+//	level < SlogLevelNotice (2)           INFO
+//	level < slog.LevelWarn (4)            NOTICE
+//	level < slog.LevelError (8)           WARNING
+//	level < SlogLevelCritical (12)        ERROR
+//	level < SlogLevelAlert (16)           CRITICAL
+//	level < SlogLevelEmergency (20)       ALERT
+//	otherwise                             EMERGENCY
+func NewSlogHandler(l *Logger) *SlogHandler {
+	if l == nil {
+		l = &std
+	}
+
+	return &SlogHandler{l: l}
+}
+
+// SlogSeverity returns the severity of the slog level, as described for NewSlogHandler.
+func SlogSeverity(level slog.Level) Severity {
+	switch {
+	case level < slog.LevelInfo:
+		return SeverityDebug
+	case level < SlogLevelNotice:
+		return SeverityInfo
+	case level < slog.LevelWarn:
+		return SeverityNotice
+	case level < slog.LevelError:
+		return SeverityWarning
+	case level < SlogLevelCritical:
+		return SeverityError
+	case level < SlogLevelAlert:
+		return SeverityCritical
+	case level < SlogLevelEmergency:
+		return SeverityAlert
+	default:
+		return SeverityEmergency
+	}
+}
+
+// Enabled reports whether the Logger of h logs the severity of the level.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.l.Enabled(SlogSeverity(level))
+}
+
+// Handle writes the record r as a log entry.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var attrs []byte
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendSlogAttr(attrs, a)
+
+		return true
+	})
+
+	buf := make([]byte, 0, 1+len(h.pre)+len(attrs)+64)
+	buf = append(buf, '{')
+	buf = append(buf, h.pre...)
+	opened := h.opened
+	if len(attrs) != 0 {
+		buf = appendSlogGroups(buf, h.pending)
+		opened += len(h.pending)
+		buf = appendComma(buf)
+		buf = append(buf, attrs...)
+	}
+	for i := 0; i < opened; i++ {
+		buf = append(buf, '}')
+	}
+	buf = append(buf, '}')
+
+	l := h.l
+	if cl, ok := ctx.Value(contextKey{}).(*Logger); ok && cl != nil && len(cl.trace) != 0 {
+		l = h.l.child(nil)
+		l.trace, l.spanID, l.sampled = cl.trace, cl.spanID, cl.sampled
+	}
+
+	msg, t, prefix := l.header(r.Message)
+	if !r.Time.IsZero() {
+		t = r.Time
+	}
+
+	return writeRawJSON(SlogSeverity(r.Level), l, msg, t, prefix, l.pcSourceLocation(r.PC), l.pcErrorReport(SlogSeverity(r.Level), r.PC), buf)
+}
+
+// WithAttrs returns a SlogHandler, which adds the attributes to every record.
+// The attributes are encoded once, by WithAttrs itself.
+func (h *SlogHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	var attrs []byte
+	for _, a := range as {
+		attrs = appendSlogAttr(attrs, a)
+	}
+
+	if len(attrs) == 0 {
+		return h
+	}
+
+	c := &SlogHandler{
+		l:      h.l,
+		pre:    make([]byte, 0, len(h.pre)+len(attrs)+32),
+		opened: h.opened + len(h.pending),
+	}
+	c.pre = append(c.pre, h.pre...)
+	c.pre = appendSlogGroups(c.pre, h.pending)
+	c.pre = appendComma(c.pre)
+	c.pre = append(c.pre, attrs...)
+
+	return c
+}
+
+// WithGroup returns a SlogHandler, which nests the subsequent attributes in the group.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	c := *h
+	c.pending = make([]string, 0, len(h.pending)+1)
+	c.pending = append(c.pending, h.pending...)
+	c.pending = append(c.pending, name)
+
+	return &c
+}
+
+// appendSlogGroups opens the JSON objects of the groups.
+func appendSlogGroups(buf []byte, groups []string) []byte {
+	for _, g := range groups {
+		buf = appendComma(buf)
+		buf = appendJSONString(buf, g)
+		buf = append(buf, ':', '{')
+	}
+
+	return buf
+}
+
+// appendSlogAttr appends the attribute as a JSON object member, preceded by a comma if needed.
+// It follows the rules of slog.Handler: the empty attributes and the empty groups are
+// omitted, and the members of a group with an empty key are inlined.
+func appendSlogAttr(buf []byte, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		var members []byte
+		for _, ga := range a.Value.Group() {
+			members = appendSlogAttr(members, ga)
+		}
+
+		if len(members) == 0 {
+			return buf
+		}
+
+		buf = appendComma(buf)
+		if a.Key == "" {
+			return append(buf, members...)
+		}
+
+		buf = appendJSONString(buf, a.Key)
+		buf = append(buf, ':', '{')
+		buf = append(buf, members...)
+
+		return append(buf, '}')
+	}
+
+	buf = appendComma(buf)
+	buf = appendJSONString(buf, a.Key)
+	buf = append(buf, ':')
+
+	return appendSlogValue(buf, a.Value)
+}
+
+// appendSlogValue appends the JSON encoding of a resolved value, which is not a group,
+// the same as of the values of Logw, see appendJSONValue.
+func appendSlogValue(buf []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return appendJSONString(buf, v.String())
+	case slog.KindInt64:
+		return strconv.AppendInt(buf, v.Int64(), 10)
+	case slog.KindUint64:
+		return strconv.AppendUint(buf, v.Uint64(), 10)
+	case slog.KindFloat64:
+		return appendJSONFloat(buf, v.Float64(), 64)
+	case slog.KindBool:
+		return strconv.AppendBool(buf, v.Bool())
+	case slog.KindDuration:
+		return appendJSONString(buf, formatDuration(v.Duration()))
+	case slog.KindTime:
+		return appendJSONValue(buf, v.Time())
+	}
+
+	return appendJSONValue(buf, v.Any())
+}
@@ -0,0 +1,140 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+var timestampRE = regexp.MustCompile(`"timestamp":"[^"]*",`)
+
+func TestSlogHandler_slogtest(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewSlogHandler(New(buf, "", 0))
+
+	results := func() []map[string]any {
+		var ms []map[string]any
+		for _, line := range bytes.Split(buf.Bytes(), []byte{'\n'}) {
+			if len(line) == 0 {
+				continue
+			}
+
+			var m map[string]any
+			if err := json.Unmarshal(line, &m); err != nil {
+				t.Fatalf("output is not a valid JSON: %v\n%s", err, line)
+			}
+
+			// Translate the Cloud Logging keys to the slog ones.
+			for from, to := range map[string]string{"message": slog.MessageKey, "severity": slog.LevelKey, "timestamp": slog.TimeKey} {
+				if v, ok := m[from]; ok {
+					m[to] = v
+					delete(m, from)
+				}
+			}
+			ms = append(ms, m)
+		}
+
+		return ms
+	}
+
+	if err := slogtest.TestHandler(h, results); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSlogSeverity(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  Severity
+	}{
+		{slog.LevelDebug, SeverityDebug},
+		{slog.LevelInfo, SeverityInfo},
+		{SlogLevelNotice, SeverityNotice},
+		{slog.LevelWarn, SeverityWarning},
+		{slog.LevelError, SeverityError},
+		{SlogLevelCritical, SeverityCritical},
+		{SlogLevelAlert, SeverityAlert},
+		{SlogLevelEmergency, SeverityEmergency},
+		{slog.LevelError + 1, SeverityError},
+		{100, SeverityEmergency},
+	}
+	for _, tt := range tests {
+		if got := SlogSeverity(tt.level); got != tt.want {
+			t.Errorf("SlogSeverity(%v) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"a","severity":"WARNING","logging.googleapis.com/trace":"projects/my-project/traces/00000000000000000000000000000001","logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace_sampled":true,"svc":"api","req":{"user":"u&1","n":2,"d":"1.5s","err":"EOF"}}
+`
+	ProjectID = "my-project"
+	defer func() { ProjectID = "" }()
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	l.SetLevel(SeverityInfo)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Cloud-Trace-Context", "00000000000000000000000000000001/1;o=1")
+	ctx := NewContext(context.Background(), ForRequest(req))
+	logger := slog.New(NewSlogHandler(l)).With("svc", "api").WithGroup("req")
+
+	// Act
+	logger.DebugContext(ctx, "discarded")
+	logger.WarnContext(ctx, "a", "user", "u&1", "n", 2, "d", 1500*time.Millisecond, "err", errEOF{})
+
+	// Assert
+	got := timestampRE.ReplaceAllString(buf.String(), "")
+	if wantJSON != got {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", buf.String(), wantJSON)
+	}
+}
+
+func TestSlogHandler_WriteError(t *testing.T) {
+	// Arrange
+	errWrite := errors.New("write")
+	l := New(nil, "", 0)
+	l.SetSink(&testSink{err: errWrite})
+	h := NewSlogHandler(l)
+
+	// Act
+	err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "a", 0))
+
+	// Assert
+	if err != errWrite {
+		t.Errorf("error %v, want %v", err, errWrite)
+	}
+}
+
+type errEOF struct{}
+
+func (errEOF) Error() string { return "EOF" }
+
+func TestSlogHandler_values(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"a","severity":"INFO","s":"<�>\u2028","f":0.5,"big":1e+21,"small":1e-7,"nan":"NaN","t":"2006-01-02T15:04:05.5Z","any":[1,2],"nilerr":null}
+`
+	buf := &bytes.Buffer{}
+	logger := slog.New(NewSlogHandler(New(buf, "", 0)))
+
+	// Act
+	logger.Info("a", "s", "<\xff>\u2028", "f", 0.5, "big", 1e21, "small", 1e-7, "nan", math.NaN(),
+		"t", time.Date(2006, 1, 2, 15, 4, 5, 5e8, time.UTC), "any", []int{1, 2}, "nilerr", (*ptrError)(nil))
+
+	// Assert
+	got := timestampRE.ReplaceAllString(buf.String(), "")
+	if wantJSON != got {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", got, wantJSON)
+	}
+}
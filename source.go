@@ -0,0 +1,86 @@
+package log
+
+import (
+	"path/filepath"
+	"runtime"
+)
+
+// SourceLocation is the LogEntrySourceLocation of the Cloud Logging API v2 as described in
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#logentrysourcelocation,
+// the location in the source code of the call, which logged an Entry.
+type SourceLocation struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,string"`
+	Function string `json:"function,omitempty"`
+}
+
+// SetSourceLocation enables or disables adding the source location of the caller, that is
+// its file, line and function, to every log entry logged through l. The Logs Explorer shows
+// it as a clickable link. Finding the caller has a cost, so it is disabled by default.
+// It is safe to call SetSourceLocation while other goroutines are logging.
+//
+// Enabling it is the same as setting the flag Llongfile, while disabling it clears both
+// the flags Llongfile and Lshortfile, see SetFlags.
+func (l *Logger) SetSourceLocation(enabled bool) {
+	flags := l.Flags() &^ (Llongfile | Lshortfile)
+	if enabled {
+		flags = l.Flags() | Llongfile
+	}
+
+	l.SetFlags(flags)
+}
+
+// SetSourceLocation enables or disables adding the source location of the caller to every
+// log entry logged by the package-level functions. See (*Logger).SetSourceLocation.
+func SetSourceLocation(enabled bool) {
+	std.SetSourceLocation(enabled)
+}
+
+// WithCallerSkip returns a child Logger, in the manner of With, which skips additional
+// stack frames when finding the source location. It is meant for the libraries that wrap
+// this package: a function that logs through a Logger with WithCallerSkip(1) reports the
+// location of its own caller, similar to the calldepth argument of Output in the standard
+// library "log" package.
+func (l *Logger) WithCallerSkip(skip int) *Logger {
+	c := l.child(nil)
+	c.callerSkip += skip
+
+	return c
+}
+
+// sourceLocation returns the source location of the user's code, or nil if l does not add
+// source locations. The depth is as described for the function log.
+func (l *Logger) sourceLocation(depth int) *SourceLocation {
+	if l.Flags()&(Llongfile|Lshortfile) == 0 {
+		return nil
+	}
+
+	// Unlike for runtime.Caller, the skip 0 of runtime.Callers is the runtime.Callers itself.
+	var pcs [1]uintptr
+	if runtime.Callers(depth+1+l.callerSkip, pcs[:]) == 0 {
+		return nil
+	}
+
+	return l.pcSourceLocation(pcs[0])
+}
+
+// pcSourceLocation returns the source location of the program counter pc as returned by
+// runtime.Callers, or nil if l does not add source locations.
+func (l *Logger) pcSourceLocation(pc uintptr) *SourceLocation {
+	flags := l.Flags()
+	if flags&(Llongfile|Lshortfile) == 0 || pc == 0 {
+		return nil
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.File == "" {
+		return nil
+	}
+
+	file := frame.File
+	if flags&Lshortfile != 0 {
+		file = filepath.Base(file)
+	}
+
+	return &SourceLocation{File: file, Line: frame.Line, Function: frame.Function}
+}
@@ -0,0 +1,99 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// lastSource decodes the source location of the last entry in buf.
+func lastSource(t *testing.T, buf *bytes.Buffer) SourceLocation {
+	t.Helper()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var e struct {
+		Source *SourceLocation `json:"logging.googleapis.com/sourceLocation"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &e); err != nil {
+		t.Fatalf("output is not a valid JSON: %v\n%s", err, buf.String())
+	}
+	if e.Source == nil {
+		t.Fatalf("no source location in:\n%s", buf.String())
+	}
+
+	return *e.Source
+}
+
+// wrappedInfo is a helper of a hypothetical library wrapping this package.
+func wrappedInfo(l *Logger, msg string) {
+	l.WithCallerSkip(1).Info(msg)
+}
+
+func TestSetSourceLocation(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+	l.SetSourceLocation(true)
+	std.out, std.err = buf, buf
+	SetSourceLocation(true)
+	defer func() {
+		std.out, std.err = nil, nil
+		SetSourceLocation(false)
+	}()
+	ctx := NewContext(context.Background(), l)
+
+	tests := []struct {
+		name string
+		log  func() int
+	}{
+		{"Logger.Info", func() int { _, _, line, _ := runtime.Caller(0); l.Info("a"); return line }},
+		{"Logger.Warningf", func() int { _, _, line, _ := runtime.Caller(0); l.Warningf("%d", 1); return line }},
+		{"Logger.Noticej", func() int { _, _, line, _ := runtime.Caller(0); l.Noticej("a", struct{}{}); return line }},
+		{"Logger.Log", func() int { _, _, line, _ := runtime.Caller(0); l.Log(SeverityAlert, "a"); return line }},
+		{"Debug", func() int { _, _, line, _ := runtime.Caller(0); Debug("a"); return line }},
+		{"Errorln", func() int { _, _, line, _ := runtime.Caller(0); Errorln("a"); return line }},
+		{"Printj", func() int { _, _, line, _ := runtime.Caller(0); Printj("a", 1); return line }},
+		{"Logf", func() int { _, _, line, _ := runtime.Caller(0); Logf(SeverityInfo, "a"); return line }},
+		{"InfoCtx", func() int { _, _, line, _ := runtime.Caller(0); InfoCtx(ctx, "a"); return line }},
+		{"CriticaljCtx", func() int { _, _, line, _ := runtime.Caller(0); CriticaljCtx(ctx, "a", nil); return line }},
+		{"With", func() int { _, _, line, _ := runtime.Caller(0); l.With("k", "v").Info("a"); return line }},
+		{"Panic", func() (line int) {
+			defer func() { _ = recover() }()
+			_, _, line, _ = runtime.Caller(0)
+			Panic("a")
+			return 0
+		}},
+		{"WithCallerSkip", func() int { _, _, line, _ := runtime.Caller(0); wrappedInfo(l, "a"); return line }},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			line := tt.log()
+			if tt.name == "Panic" {
+				line++
+			}
+
+			got := lastSource(t, buf)
+			if !strings.HasSuffix(got.File, "source_test.go") || got.Line != line {
+				t.Errorf("source location = %s:%d, want source_test.go:%d", got.File, got.Line, line)
+			}
+			if !strings.Contains(got.Function, "TestSetSourceLocation") {
+				t.Errorf("function = %q", got.Function)
+			}
+		})
+	}
+}
+
+func TestSetSourceLocationDisabled(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf, "", 0)
+
+	l.Info("a")
+
+	if strings.Contains(buf.String(), "sourceLocation") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
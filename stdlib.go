@@ -0,0 +1,189 @@
+package log
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// These flags define the extra information added to every log entry, in the manner
+// of the standard library "log" package. Instead of a text header, the information
+// becomes the fields of the entry:
+//
+// Ldate, Ltime and Lmicroseconds add the "timestamp" field, which Cloud Logging uses as
+// the time of the entry instead of the time of receiving it. Any of them adds the date and
+// the time, with a precision of seconds, unless Lmicroseconds is set.
+//
+// Llongfile and Lshortfile add the "logging.googleapis.com/sourceLocation" field,
+// see SetSourceLocation.
+//
+// Lmsgprefix moves the prefix, see SetPrefix, to the beginning of the message.
+// Without it, a non-empty prefix becomes the "prefix" field.
+const (
+	Ldate         = 1 << iota     // the timestamp in the local time zone
+	Ltime                         // the timestamp in the local time zone
+	Lmicroseconds                 // the timestamp with a microsecond resolution
+	Llongfile                     // the full file name, the line number and the function
+	Lshortfile                    // the final file name element, overrides Llongfile
+	LUTC                          // the timestamp in UTC rather than the local time zone
+	Lmsgprefix                    // the prefix at the beginning of the message instead of a field
+	LstdFlags     = Ldate | Ltime // initial values for the standard logger of the "log" package
+)
+
+// SetOutput sets the destination of all the messages logged through l, regardless of
+// their severity. A child Logger, such as created by With or ForRequest, shares its
+// writers with its parent, so SetOutput affects both. It is safe to call SetOutput
+// while other goroutines are logging.
+func (l *Logger) SetOutput(w io.Writer) {
+	r := l.root()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.out = w
+	r.err = w
+}
+
+// Writer returns the destination of the messages logged through l, the one of the
+// severities below ERROR, if they differ.
+func (l *Logger) Writer() io.Writer {
+	r := l.root()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.writer(SeverityInfo)
+}
+
+// Flush flushes the destinations of the messages logged through l, which buffer them,
+// such as AsyncWriter or bufio.Writer, that is the ones with a method Flush() error,
+// and the Sink of l, if any, see SetSink. The Fatal functions call Flush before exiting,
+// and the Panic functions before panicking.
+func (l *Logger) Flush() error {
+	r := l.root()
+	r.mu.Lock()
+	out, err := r.writer(SeverityInfo), r.writer(SeverityError)
+	r.mu.Unlock()
+
+	// Flushing the same writer twice, if out and err are the same, is harmless.
+	ferr := flushWriter(out)
+	if e := flushWriter(err); ferr == nil {
+		ferr = e
+	}
+	if s := l.Sink(); s != nil {
+		if e := flushSink(s); ferr == nil {
+			ferr = e
+		}
+	}
+
+	return ferr
+}
+
+// SetFlags sets the flags of l, such as Lshortfile or Lmicroseconds. Unlike in the
+// standard library "log" package, by default no flags are set, as Cloud Logging records
+// the time of every entry anyway. It is safe to call SetFlags while other goroutines are logging.
+func (l *Logger) SetFlags(flag int) {
+	l.flags.store(int32(flag))
+}
+
+// Flags returns the flags of l.
+func (l *Logger) Flags() int {
+	return int(l.load(func(l *Logger) *setting { return &l.flags }))
+}
+
+// SetPrefix sets the prefix of l. Depending on the flag Lmsgprefix, it is either
+// the "prefix" field of every entry or the beginning of every message. It is safe to
+// call SetPrefix while other goroutines are logging.
+func (l *Logger) SetPrefix(prefix string) {
+	l.prefix.Store(prefix)
+}
+
+// Prefix returns the prefix of l.
+func (l *Logger) Prefix() string {
+	prefix, _ := l.loadValue(func(l *Logger) *atomic.Value { return &l.prefix }).(string)
+
+	return prefix
+}
+
+// Output logs the message s with the severity INFO. The calldepth is the number of stack
+// frames to skip when finding the source location, if enabled; a value of 1 finds the caller
+// of Output. Output returns the error of writing the entry, if any. It is for compatibility
+// with the standard library "log" package.
+func (l *Logger) Output(calldepth int, s string) error {
+	return logs(SeverityInfo, l, calldepth+1, s)
+}
+
+// header returns the message, the time and the prefix field of an entry, as required by
+// the flags and the prefix of l. The time is zero, if the flags omit the timestamp.
+func (l *Logger) header(msg string) (string, time.Time, string) {
+	flags := l.Flags()
+	prefix := l.Prefix()
+
+	if flags&Lmsgprefix != 0 {
+		msg = prefix + msg
+		prefix = ""
+	}
+
+	var t time.Time
+	if flags&(Ldate|Ltime|Lmicroseconds) != 0 {
+		t = time.Now()
+		if flags&LUTC != 0 {
+			t = t.UTC()
+		}
+
+		if flags&Lmicroseconds != 0 {
+			t = t.Truncate(time.Microsecond)
+		} else {
+			t = t.Truncate(time.Second)
+		}
+	}
+
+	return msg, t, prefix
+}
+
+// Default returns the package-level logger, the one used by functions like Info.
+func Default() *Logger {
+	return &std
+}
+
+// SetOutput sets the destination of all the messages logged by the package-level
+// functions, and by the Loggers created by ForRequest. By default, the messages of
+// severity ERROR and above are written to os.Stderr and the remaining ones to os.Stdout.
+func SetOutput(w io.Writer) {
+	std.SetOutput(w)
+}
+
+// Writer returns the destination of the messages logged by the package-level functions,
+// the one of the severities below ERROR, if they differ.
+func Writer() io.Writer {
+	return std.Writer()
+}
+
+// Flush flushes the destinations of the messages logged by the package-level functions.
+// See (*Logger).Flush.
+func Flush() error {
+	return std.Flush()
+}
+
+// SetFlags sets the flags of the package-level logger. See (*Logger).SetFlags.
+func SetFlags(flag int) {
+	std.SetFlags(flag)
+}
+
+// Flags returns the flags of the package-level logger.
+func Flags() int {
+	return std.Flags()
+}
+
+// SetPrefix sets the prefix of the package-level logger. See (*Logger).SetPrefix.
+func SetPrefix(prefix string) {
+	std.SetPrefix(prefix)
+}
+
+// Prefix returns the prefix of the package-level logger.
+func Prefix() string {
+	return std.Prefix()
+}
+
+// Output logs the message s with the severity INFO through the package-level logger.
+// See (*Logger).Output.
+func Output(calldepth int, s string) error {
+	return logs(SeverityInfo, &std, calldepth+1, s)
+}
@@ -0,0 +1,154 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNew_PrefixAndFlags(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		flag   int
+		want   string
+	}{{
+		name:   "prefix field",
+		prefix: "app: ",
+		want: `{"message":"a","severity":"INFO","prefix":"app: "}
+`,
+	}, {
+		name:   "message prefix",
+		prefix: "app: ",
+		flag:   Lmsgprefix,
+		want: `{"message":"app: a","severity":"INFO"}
+`,
+	}, {
+		name:   "timestamp",
+		prefix: "",
+		flag:   LstdFlags | LUTC,
+		want: `{"message":"a","severity":"INFO","timestamp":"\d{4}-\d\d-\d\dT\d\d:\d\d:\d\dZ"}
+`,
+	}, {
+		name:   "microseconds",
+		prefix: "",
+		flag:   Lmicroseconds | LUTC,
+		want: `{"message":"a","severity":"INFO","timestamp":"\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}Z"}
+`,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			l := New(buf, tt.prefix, tt.flag)
+
+			l.Print("a")
+			l.Printj("a", struct{}{})
+
+			want := regexp.MustCompile("^" + strings.Repeat(tt.want, 2) + "$")
+			if !want.MatchString(buf.String()) {
+				t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", buf.String(), tt.want)
+			}
+			if l.Prefix() != tt.prefix || l.Flags() != tt.flag {
+				t.Errorf("Prefix() = %q, Flags() = %d", l.Prefix(), l.Flags())
+			}
+		})
+	}
+}
+
+func TestLogger_Lshortfile(t *testing.T) {
+	// Arrange
+	buf := &bytes.Buffer{}
+	l := New(buf, "", Lshortfile)
+
+	// Act
+	_, _, line, _ := runtime.Caller(0)
+	l.Info("a")
+
+	// Assert
+	got := lastSource(t, buf)
+	if got.File != "stdlib_test.go" || got.Line != line+1 {
+		t.Errorf("source location = %s:%d, want stdlib_test.go:%d", got.File, got.Line, line+1)
+	}
+}
+
+// output is a helper of a hypothetical library wrapping this package.
+func output(l *Logger, s string) error {
+	return l.Output(2, s)
+}
+
+func TestLogger_Output(t *testing.T) {
+	// Arrange
+	buf := &bytes.Buffer{}
+	l := New(buf, "", Lshortfile)
+
+	// Act
+	_, _, line, _ := runtime.Caller(0)
+	err := output(l, "a")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := lastSource(t, buf)
+	if got.File != "stdlib_test.go" || got.Line != line+1 {
+		t.Errorf("source location = %s:%d, want stdlib_test.go:%d", got.File, got.Line, line+1)
+	}
+	var e map[string]interface{}
+	_ = json.Unmarshal(buf.Bytes(), &e)
+	if e["message"] != "a" || e["severity"] != "INFO" {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestLogger_SetOutput(t *testing.T) {
+	// Arrange
+	l := New(ioutil.Discard, "", 0)
+	c := l.With("k", "v")
+	buf := &bytes.Buffer{}
+	var wg sync.WaitGroup
+
+	// Act
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			c.Error("test")
+		}
+	}()
+	l.SetOutput(buf)
+	wg.Wait()
+	c.Error("last")
+
+	// Assert
+	if l.Writer() != buf || c.Writer() != buf {
+		t.Errorf("Writer() is not the one set by SetOutput")
+	}
+	if !strings.HasSuffix(buf.String(), `{"message":"last","severity":"ERROR","k":"v"}`+"\n") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"a","severity":"DEBUG"}
+{"message":"b","severity":"ERROR"}
+`
+	buf := &bytes.Buffer{}
+	SetOutput(buf)
+	defer SetOutput(nil)
+
+	// Act
+	Debug("a")
+	Default().Error("b")
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q\n", buf.String(), wantJSON)
+	}
+}
@@ -0,0 +1,113 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// traceContext is the trace of an HTTP request, as parsed from its headers.
+type traceContext struct {
+	traceID string // hexadecimal
+	spanID  string // 16 hexadecimal digits, or empty if unknown
+	sampled string // "true", "false", or empty if unknown
+}
+
+// parseCloudTraceContext parses the value of the "X-Cloud-Trace-Context" header. It reports
+// whether the header carried a usable trace ID.
+func parseCloudTraceContext(h string) (tc traceContext, ok bool) {
+	// "X-Cloud-Trace-Context: TRACE_ID/SPAN_ID;o=TRACE_TRUE" meaning:
+	// TRACE_ID is a 32-character hexadecimal value representing a 128-bit number. [Future-proofing to 256-char.]
+	// SPAN_ID is the decimal representation of [unsigned integer of unspecified bitlength].
+	// TRACE_TRUE must be `1` to trace this request. Specify `0` to not trace the request.
+	i := strings.IndexByte(h, '/')
+	if i <= 0 || i > 256 {
+		return tc, false
+	}
+
+	t := h[:i]
+	if strings.TrimLeft(t, "0123456789abcdefABCDEFxX") != "" {
+		return tc, false
+	}
+
+	if strings.Count(t, "0") == len(t) {
+		return tc, false
+	}
+
+	tc.traceID = t
+
+	span := h[i+1:]
+	if j := strings.IndexByte(span, ';'); j >= 0 {
+		switch span[j:] {
+		case ";o=1":
+			tc.sampled = "true"
+		case ";o=0":
+			tc.sampled = "false"
+		}
+
+		span = span[:j]
+	}
+
+	// Cloud Trace expects the span ID as exactly 16 hexadecimal digits, while the header
+	// carries a decimal number. The span ID 0 is invalid.
+	if n, err := strconv.ParseUint(span, 10, 64); err == nil && n != 0 {
+		tc.spanID = fmt.Sprintf("%016x", n)
+	}
+
+	return tc, true
+}
+
+// parseTraceparent parses the value of the W3C "traceparent" header, as specified in
+// https://www.w3.org/TR/trace-context/#traceparent-header. It reports whether the value
+// is valid.
+func parseTraceparent(h string) (tc traceContext, ok bool) {
+	// "traceparent: VERSION-TRACE_ID-PARENT_ID-TRACE_FLAGS" meaning:
+	// VERSION is 2 hexadecimal digits, "ff" is forbidden.
+	// TRACE_ID is 32 hexadecimal digits, not all zeroes.
+	// PARENT_ID is the 16 hexadecimal digits of the caller's span ID, not all zeroes.
+	// TRACE_FLAGS is 2 hexadecimal digits, the lowest bit being the sampling decision.
+	// All the digits are lower-case. Future versions may append more fields after a '-'.
+	h = strings.TrimSpace(h)
+	if len(h) < 55 || h[2] != '-' || h[35] != '-' || h[52] != '-' {
+		return tc, false
+	}
+
+	version, traceID, parentID, flags := h[:2], h[3:35], h[36:52], h[53:55]
+	if !isLowerHex(version) || version == "ff" {
+		return tc, false
+	}
+
+	if version == "00" && len(h) != 55 {
+		return tc, false
+	}
+
+	if len(h) > 55 && h[55] != '-' {
+		return tc, false
+	}
+
+	if !isLowerHex(traceID) || !isLowerHex(parentID) || !isLowerHex(flags) {
+		return tc, false
+	}
+
+	if strings.Count(traceID, "0") == len(traceID) || strings.Count(parentID, "0") == len(parentID) {
+		return tc, false
+	}
+
+	f, _ := strconv.ParseUint(flags, 16, 8)
+
+	tc.traceID = traceID
+	tc.spanID = parentID
+	tc.sampled = strconv.FormatBool(f&1 == 1)
+
+	return tc, true
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,78 @@
+package log
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   traceContext
+		wantOK bool
+	}{{
+		name:   "sampled",
+		header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		want:   traceContext{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "true"},
+		wantOK: true,
+	}, {
+		name:   "not sampled",
+		header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		want:   traceContext{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "false"},
+		wantOK: true,
+	}, {
+		name:   "other flags",
+		header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03",
+		want:   traceContext{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "true"},
+		wantOK: true,
+	}, {
+		name:   "surrounding spaces",
+		header: " 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01 ",
+		want:   traceContext{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "true"},
+		wantOK: true,
+	}, {
+		name:   "future version with more fields",
+		header: "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future-holds",
+		want:   traceContext{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "true"},
+		wantOK: true,
+	}, {
+		name:   "version 00 with more fields",
+		header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-x",
+	}, {
+		name:   "future version with garbage",
+		header: "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x",
+	}, {
+		name:   "forbidden version",
+		header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}, {
+		name:   "upper case",
+		header: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01",
+	}, {
+		name:   "zero trace id",
+		header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+	}, {
+		name:   "zero parent id",
+		header: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+	}, {
+		name:   "short",
+		header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+	}, {
+		name:   "bad separator",
+		header: "00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01",
+	}, {
+		name: "empty",
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseTraceparent(tt.header)
+			if ok != tt.wantOK {
+				t.Fatalf("parseTraceparent() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTraceparent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
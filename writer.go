@@ -0,0 +1,160 @@
+package log
+
+import (
+	"bytes"
+	stdlog "log"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxLineSize is the size of the longest message logged by EntryWriter, well below
+// the 256 KiB limit of a Cloud Logging entry. The longer lines are split into several entries.
+const maxLineSize = 64 * 1024
+
+// EntryWriter is an io.Writer, which turns the written text into log entries of a fixed
+// severity. Create it with NewEntryWriter.
+//
+// Every line of the text becomes an entry, without its newline, regardless of how the text
+// is split between the Writes. The last line of a Write, which does not end with a newline,
+// is buffered until a later Write completes it, or until Flush. The lines longer than 64 KiB
+// are split into several entries of at most 64 KiB each.
+//
+// The entries have no source location, see SetSourceLocation, as the caller of Write is
+// usually a function of a package like "fmt" or the standard library "log".
+type EntryWriter struct {
+	l   *Logger
+	s   Severity
+	mu  sync.Mutex
+	buf []byte
+}
+
+// NewEntryWriter returns an EntryWriter, which logs through l, or through the package-level
+// logger if l is nil, with the severity s. Use a Logger created by ForRequest to have the
+// entries trace back to the HTTP request.
+func NewEntryWriter(l *Logger, s Severity) *EntryWriter {
+	if l == nil {
+		l = &std
+	}
+
+	return &EntryWriter{l: l, s: s}
+}
+
+// Write buffers p and logs the lines it completes, if any. It always returns len(p), nil.
+func (w *EntryWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	if bytes.IndexByte(p, '\n') >= 0 || len(w.buf) >= maxLineSize {
+		w.logLocked(false)
+	}
+
+	return len(p), nil
+}
+
+// Flush logs the buffered text, if any, even though it does not end with a newline.
+func (w *EntryWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) != 0 {
+		w.logLocked(true)
+	}
+
+	return nil
+}
+
+// Close is the same as Flush.
+func (w *EntryWriter) Close() error {
+	return w.Flush()
+}
+
+// logLocked logs the complete lines of the buffered text. Unless flush is true, it keeps
+// buffered the last line without a newline, or its tail shorter than maxLineSize.
+func (w *EntryWriter) logLocked(flush bool) {
+	text := w.buf
+	for {
+		i := bytes.IndexByte(text, '\n')
+		if i < 0 {
+			break
+		}
+		w.logLine(text[:i])
+		text = text[i+1:]
+	}
+
+	if flush {
+		w.logLine(text)
+		text = nil
+	}
+
+	for len(text) >= maxLineSize {
+		n := chunkSize(text)
+		w.logEntry(text[:n])
+		text = text[n:]
+	}
+
+	w.buf = append(w.buf[:0], text...)
+}
+
+// logLine logs the line, without its newline, in the entries of at most maxLineSize bytes.
+func (w *EntryWriter) logLine(line []byte) {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	for len(line) > maxLineSize {
+		n := chunkSize(line)
+		w.logEntry(line[:n])
+		line = line[n:]
+	}
+
+	w.logEntry(line)
+}
+
+// logEntry logs msg as one entry without a source location.
+func (w *EntryWriter) logEntry(msg []byte) {
+	if !w.l.Enabled(w.s) {
+		return
+	}
+
+	m, t, prefix := w.l.header(string(msg))
+	e := Entry{
+		Severity: w.s,
+		Message:  m,
+		Time:     t,
+		Prefix:   prefix,
+	}
+	_ = w.l.write(&e, w.l.pcErrorReport(w.s, 0), nil)
+}
+
+// chunkSize returns the size of the first entry of text, at most maxLineSize, so that
+// the text is split between the UTF-8 characters.
+func chunkSize(text []byte) int {
+	if len(text) <= maxLineSize {
+		return len(text)
+	}
+
+	n := maxLineSize
+	for n > maxLineSize-utf8.UTFMax && !utf8.RuneStart(text[n]) {
+		n--
+	}
+
+	return n
+}
+
+// RedirectStdLog makes the standard library "log" package log through l, or through
+// the package-level logger if l is nil, with the severity s. It also clears the flags
+// of the standard library logger, as the date and the time are recorded by Cloud Logging
+// anyway. The returned function undoes it, restoring the flags and setting the output back
+// to os.Stderr, the default of the standard library.
+func RedirectStdLog(l *Logger, s Severity) (restore func()) {
+	w := NewEntryWriter(l, s)
+	flags := stdlog.Flags()
+
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(w)
+
+	return func() {
+		stdlog.SetOutput(os.Stderr)
+		stdlog.SetFlags(flags)
+		_ = w.Flush()
+	}
+}
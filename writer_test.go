@@ -0,0 +1,170 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	stdlog "log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEntryWriter(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"a","severity":"WARNING"}
+{"message":"partial write","severity":"WARNING"}
+{"message":"multi","severity":"WARNING"}
+{"message":"line","severity":"WARNING"}
+{"message":"b","severity":"WARNING"}
+{"message":"cd","severity":"WARNING"}
+{"message":"","severity":"WARNING"}
+{"message":"crlf","severity":"WARNING"}
+{"message":"unterminated","severity":"WARNING"}
+`
+	buf := &bytes.Buffer{}
+	w := NewEntryWriter(New(buf, "", 0), SeverityWarning)
+
+	// Act
+	fmt.Fprintln(w, "a")
+	fmt.Fprint(w, "partial")
+	fmt.Fprint(w, " write\n")
+	fmt.Fprint(w, "multi\nline\n")
+	fmt.Fprint(w, "b\nc")
+	fmt.Fprint(w, "d\n")
+	fmt.Fprint(w, "\n")
+	fmt.Fprint(w, "crlf\r\n")
+	fmt.Fprint(w, "unterminated")
+	if buf.Len() != len(wantJSON)-len(`{"message":"unterminated","severity":"WARNING"}`+"\n") {
+		t.Errorf("unterminated text was logged before Close:\n%s", buf.String())
+	}
+	_ = w.Close()
+	_ = w.Close()
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", buf.String(), wantJSON)
+	}
+}
+
+func TestEntryWriter_LongLine(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewEntryWriter(New(buf, "", 0), SeverityInfo)
+
+	_, _ = w.Write(bytes.Repeat([]byte{'x'}, maxLineSize))
+
+	if buf.Len() == 0 {
+		t.Errorf("a line longer than %d bytes was not logged", maxLineSize)
+	}
+}
+
+func TestEntryWriter_NoSourceLocation(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"a","severity":"INFO"}` + "\n"
+	buf := &bytes.Buffer{}
+	l := New(buf, "", Lshortfile)
+	w := NewEntryWriter(l, SeverityInfo)
+
+	// Act
+	fmt.Fprintln(w, "a")
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", buf.String(), wantJSON)
+	}
+}
+
+func TestEntryWriter_Split(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		wantLogged []int
+		wantFlush  []int
+	}{
+		{
+			name:       "300 KiB",
+			text:       strings.Repeat("x", 300<<10),
+			wantLogged: []int{maxLineSize, maxLineSize, maxLineSize, maxLineSize},
+			wantFlush:  []int{300<<10 - 4*maxLineSize},
+		},
+		{
+			name:       "300 KiB line",
+			text:       strings.Repeat("x", 300<<10) + "\n",
+			wantLogged: []int{maxLineSize, maxLineSize, maxLineSize, maxLineSize, 300<<10 - 4*maxLineSize},
+		},
+		{
+			name:       "character on the boundary",
+			text:       strings.Repeat("x", maxLineSize-1) + "\u00e9\n",
+			wantLogged: []int{maxLineSize - 1, 2},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			buf := &bytes.Buffer{}
+			w := NewEntryWriter(New(buf, "", 0), SeverityInfo)
+
+			// Act
+			_, _ = w.Write([]byte(tt.text))
+			logged := entrySizes(t, buf.String())
+			buf.Reset()
+			_ = w.Flush()
+			flushed := entrySizes(t, buf.String())
+
+			// Assert
+			if fmt.Sprint(logged) != fmt.Sprint(tt.wantLogged) || fmt.Sprint(flushed) != fmt.Sprint(tt.wantFlush) {
+				t.Errorf("logged %v and flushed %v, want %v and %v", logged, flushed, tt.wantLogged, tt.wantFlush)
+			}
+		})
+	}
+}
+
+// entrySizes returns the sizes of the messages of the JSON entries, one per line, which
+// must be valid UTF-8.
+func entrySizes(t *testing.T, out string) []int {
+	var sizes []int
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var e struct{ Message string }
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("entry is not a valid JSON: %v", err)
+		}
+		if !utf8.ValidString(e.Message) {
+			t.Errorf("the message %q is not valid UTF-8", e.Message)
+		}
+		sizes = append(sizes, len(e.Message))
+	}
+
+	return sizes
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	// Arrange
+	wantJSON := `{"message":"lib: failed","severity":"ERROR","logging.googleapis.com/trace":"projects/my-project/traces/00000000000000000000000000000001","logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace_sampled":true}
+{"message":"to connect","severity":"ERROR","logging.googleapis.com/trace":"projects/my-project/traces/00000000000000000000000000000001","logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace_sampled":true}
+`
+	ProjectID = "my-project"
+	defer func() { ProjectID = "" }()
+	buf := &bytes.Buffer{}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Cloud-Trace-Context", "00000000000000000000000000000001/1;o=1")
+	flags := stdlog.Flags()
+
+	// Act
+	restore := RedirectStdLog(New(buf, "", 0).ForRequest(req), SeverityError)
+	stdlog.Printf("lib: failed\nto connect")
+	restore()
+
+	// Assert
+	if wantJSON != buf.String() {
+		t.Errorf("unexpected output, got:\n%s\nexpected:\n%s\n", buf.String(), wantJSON)
+	}
+	if stdlog.Flags() != flags {
+		t.Errorf("flags not restored: %d, want %d", stdlog.Flags(), flags)
+	}
+}